@@ -1,9 +1,17 @@
 package blake2xs
 
 import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"hash"
 	"io"
+	"runtime"
+	"sync"
 
 	"github.com/dchest/blake2s"
 )
@@ -11,8 +19,62 @@ import (
 // UnknownSize is used when the output size of XOF is unknown beforehand. It
 // can be used to read as many bytes as required from the XOF up to its value.
 // For unknown output size, shorter outputs are prefixes of longer outputs.
+//
+// UnknownSize is also the maximum output size of BLAKE2Xs, which is a hard
+// limit: after reading UnknownSize bytes, Read returns io.EOF just like for
+// XOF of a known size. Use Remaining to check how many bytes can be read.
 const UnknownSize = 1<<16 - 1
 
+// MaxSize is the maximum output size of XOF in bytes.
+const MaxSize = UnknownSize
+
+// BlockSize is the size of XOF output block in bytes. The last block
+// is shorter if output size is not a multiple of BlockSize.
+const BlockSize = blake2s.Size
+
+// Errors returned by XOF functions and methods.
+var (
+	ErrWriteAfterRead   = errors.New("blake2xs: cannot write after reading")
+	ErrSize             = errors.New("blake2xs: invalid output size")
+	ErrSizeMismatch     = errors.New("blake2xs: config size doesn't match output length")
+	ErrNegativeOffset   = errors.New("blake2xs: negative offset")
+	ErrNegativePosition = errors.New("blake2xs: negative position")
+	ErrNegativeCount    = errors.New("blake2xs: negative count")
+	ErrInvalidWhence    = errors.New("blake2xs: invalid whence")
+	ErrSeekOverflow     = errors.New("blake2xs: seek position overflows")
+	ErrNoMarshal        = errors.New("blake2xs: root hash doesn't support marshaling")
+	ErrKeySize          = errors.New("blake2xs: key too long (max 32 bytes)")
+	ErrSaltSize         = errors.New("blake2xs: salt too long (max 8 bytes)")
+	ErrPersonSize       = errors.New("blake2xs: personalization too long (max 8 bytes)")
+	ErrTree             = errors.New("blake2xs: invalid tree parameters")
+	ErrNodeOffset       = errors.New("blake2xs: tree node offset is too large")
+	ErrSetSize          = errors.New("blake2xs: cannot change size after writing or reading")
+	ErrBlockIndex       = errors.New("blake2xs: block index out of range")
+	ErrBlockLength      = errors.New("blake2xs: wrong output block length")
+	ErrBlockAlign       = errors.New("blake2xs: position is not at block boundary")
+	ErrSourceType       = errors.New("blake2xs: unsupported source type")
+	ErrShortInput       = errors.New("blake2xs: input is shorter than required")
+	ErrNoInput          = errors.New("blake2xs: reading before writing input")
+	ErrBatchLength      = errors.New("blake2xs: number of outputs and inputs differ")
+	ErrBufferSize       = errors.New("blake2xs: negative buffer size")
+	ErrNoRootState      = errors.New("blake2xs: root hash state is not available")
+	ErrInvalidState     = errors.New("blake2xs: invalid hash state")
+	ErrStateVersion     = errors.New("blake2xs: unsupported hash state version")
+	ErrStateSize        = errors.New("blake2xs: hash state has different output size")
+	ErrNotFinalized     = errors.New("blake2xs: root hash is not finalized")
+	ErrNonceSize        = errors.New("blake2xs: wrong nonce size")
+	ErrVectorMismatch   = errors.New("blake2xs: output doesn't match test vector")
+	ErrRoundTrip        = errors.New("blake2xs: read paths produce different output")
+)
+
+// bufferSize is the default size of buffer used for streaming.
+const bufferSize = 32 * blake2s.Size
+
+// Backend creates a BLAKE2s hash instance with the given parameters.
+// It must return hashes compatible with blake2s.New, and be safe for
+// concurrent use, like BlockPerson.
+type Backend func(c *blake2s.Config) (hash.Hash, error)
+
 // Config is used to configure hash function parameters and keying.
 // All parameters are optional.
 type Config struct {
@@ -21,109 +83,1306 @@ type Config struct {
 	Salt   []byte        // salt (if < 8 bytes, padded with zeros)
 	Person []byte        // personalization (if < 8 bytes, padded with zeros)
 	Tree   *blake2s.Tree // parameters for tree hashing
+
+	// MinInput is the minimum number of input bytes. If fewer bytes
+	// are written, finalization fails with ErrShortInput.
+	MinInput int
+
+	// Strict, if true, makes reading output fail with ErrNoInput
+	// unless Write was called at least once, possibly with empty
+	// input, to catch reading from XOF that was never written to.
+	// Writing after reading always fails with ErrWriteAfterRead.
+	Strict bool
+
+	// Tap, if not nil, receives a copy of all input written to XOF.
+	// Errors from it are returned by Write.
+	Tap io.Writer
+
+	// BlockPerson, if not nil, returns personalization for the output
+	// block with the given index, which is used instead of Person.
+	// It must be safe for concurrent use, since ReadAt and ReadParallel
+	// call it from multiple goroutines.
+	//
+	// Output with BlockPerson is not standard BLAKE2Xs.
+	BlockPerson func(i uint64) []byte
+
+	// NoShortLastBlock, if true, makes the last output block a full
+	// BLAKE2s-256 digest truncated to the remaining length, instead of
+	// a digest of that length.
+	//
+	// Output with NoShortLastBlock is not standard BLAKE2Xs.
+	NoShortLastBlock bool
+
+	// BufferSize is the size of buffer used by ReadFrom, WriteTo and
+	// WriteN, rounded up to a multiple of BlockSize. If zero, the
+	// default of 1024 bytes is used. It doesn't affect output.
+	BufferSize int
+
+	// Backend, if not nil, is used instead of blake2s.New
+	// to create root and output hashes.
+	Backend Backend
 }
 
-type xof struct {
-	rh   hash.Hash          // root hash instance
-	oc   blake2s.Config     // output config
-	h0   []byte             // root hash digest, nil if not finalized yet
-	x    [blake2s.Size]byte // buffer for output
-	px   int                // position in output buffer
-	left int                // number of output bytes left to generate
+// Validate checks that config parameters are within BLAKE2s limits.
+//
+// Tree node offset must fit into 32 bits, since BLAKE2Xs stores output
+// size in the upper bits of BLAKE2s node offset.
+func (c *Config) Validate() error {
+	if len(c.Key) > blake2s.Size {
+		return ErrKeySize
+	}
+	if len(c.Salt) > 8 {
+		return ErrSaltSize
+	}
+	if len(c.Person) > 8 {
+		return ErrPersonSize
+	}
+	if c.MinInput < 0 {
+		return ErrNegativeCount
+	}
+	if c.BufferSize < 0 {
+		return ErrBufferSize
+	}
+	if t := c.Tree; t != nil {
+		if t.MaxDepth == 0 || t.NodeDepth >= t.MaxDepth || t.InnerHashSize > blake2s.Size {
+			return ErrTree
+		}
+		if t.NodeOffset > 1<<32-1 {
+			return ErrNodeOffset
+		}
+	}
+	return nil
+}
+
+// NodeOffsetFor returns the node offset of the root hash for XOF with the
+// given output size and tree parameters, which may be nil: output size is
+// stored in the upper 32 bits, and baseTree node offset in the lower bits.
+// As in Config, zero size means UnknownSize. Output block i uses node offset
+// NodeOffsetFor(size, nil) + i regardless of tree parameters.
+//
+// NodeOffsetFor panics if size is not between 0 and UnknownSize.
+func NodeOffsetFor(size int, baseTree *blake2s.Tree) uint64 {
+	if !validSize(size) {
+		panic(ErrSize)
+	}
+	if size == 0 {
+		size = UnknownSize
+	}
+	off := uint64(size) << 32
+	if baseTree != nil {
+		off += baseTree.NodeOffset
+	}
+	return off
+}
+
+// XOF is an extended output function. Input is written with Write, and
+// output is read with Read. After reading has begun, no more input
+// can be written.
+type XOF struct {
+	rh     hash.Hash             // root hash instance
+	nh     Backend               // creates hash instances, nil for blake2s.New
+	rc     blake2s.Config        // root hash config
+	oc     blake2s.Config        // output config template
+	h0     [blake2s.Size]byte    // root hash digest
+	fin    bool                  // true if root hash is finalized
+	x      [blake2s.Size]byte    // buffer for output
+	px     int                   // position in output buffer
+	left   int                   // number of output bytes left to generate
+	next   int                   // index of the next output block
+	size   int                   // output size
+	n      int64                 // number of bytes written to root hash
+	min    int64                 // minimum number of input bytes
+	err    error                 // finalization error
+	wrote  bool                  // true if Write was called
+	strict bool                  // require Write before Read
+	tap    io.Writer             // receives copy of input
+	bp     func(i uint64) []byte // block personalization
+	full   bool                  // don't shorten the last output block
+	stale  bool                  // root hash state doesn't match root digest
+	buf    []byte                // scratch buffer for streaming, allocated lazily
+	bufLen int                   // configured size of scratch buffer
+	mu     sync.Mutex            // protects root hash finalization
 }
 
 // NewXOF returns a new extended output function.
-func NewXOF(c *Config) (io.ReadWriter, error) {
+func NewXOF(c *Config) (*XOF, error) {
 	if c == nil {
 		c = &Config{Size: UnknownSize}
 	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
 
 	outSize := int(c.Size)
 	if outSize == 0 {
 		outSize = UnknownSize
 	}
 
+	// Copy parameters, so that later changes to caller's slices
+	// don't affect us, and we can clear the key.
+	key := append([]byte(nil), c.Key...)
+	salt := append([]byte(nil), c.Salt...)
+	person := append([]byte(nil), c.Person...)
+
 	// Create root hash config.
 	rc := blake2s.Config{
 		Size:   blake2s.Size,
-		Key:    c.Key,
-		Salt:   c.Salt,
-		Person: c.Person,
-		Tree:   c.Tree,
+		Key:    key,
+		Salt:   salt,
+		Person: person,
 	}
 
-	if rc.Tree == nil {
-		rc.Tree = &blake2s.Tree{
-			Fanout:   1,
-			MaxDepth: 1,
-		}
+	// Copy tree parameters, so that caller's config is not modified.
+	tree := blake2s.Tree{
+		Fanout:   1,
+		MaxDepth: 1,
+	}
+	if c.Tree != nil {
+		tree = *c.Tree
 	}
-	rc.Tree.NodeOffset += uint64(outSize) << 32
+	tree.NodeOffset = NodeOffsetFor(outSize, c.Tree)
+	rc.Tree = &tree
 
 	// Create initial config for output hashes.
 	oc := blake2s.Config{
 		Size:   blake2s.Size,
-		Salt:   c.Salt,
-		Person: c.Person,
+		Salt:   salt,
+		Person: person,
 		Tree: &blake2s.Tree{
 			Fanout:        0,
 			MaxDepth:      0,
 			LeafSize:      blake2s.Size,
-			NodeOffset:    uint64(outSize) << 32,
+			NodeOffset:    NodeOffsetFor(outSize, nil),
 			NodeDepth:     0,
 			InnerHashSize: blake2s.Size,
 			IsLastNode:    false,
 		},
 	}
 
-	rh, err := blake2s.New(&rc)
+	rh, err := newHash(c.Backend, &rc)
 	if err != nil {
 		return nil, err
 	}
 
-	return &xof{
-		rh:   rh,
-		oc:   oc,
-		px:   blake2s.Size, // set to digest size
-		left: outSize,
+	return &XOF{
+		rh:     rh,
+		nh:     c.Backend,
+		min:    int64(c.MinInput),
+		strict: c.Strict,
+		tap:    c.Tap,
+		bp:     c.BlockPerson,
+		full:   c.NoShortLastBlock,
+		bufLen: c.BufferSize,
+		rc:     rc,
+		oc:     oc,
+		px:     blake2s.Size, // set to digest size
+		left:   outSize,
+		size:   outSize,
 	}, nil
 }
 
-func (x *xof) Write(p []byte) (nn int, err error) {
-	if x.h0 != nil {
-		return 0, errors.New("blake2xs: cannot write after reading")
+// validSize reports whether size is a valid output size, which is
+// from 0 to UnknownSize. Functions taking int sizes must check it
+// before converting size to uint16.
+func validSize(size int) bool { return size >= 0 && size <= UnknownSize }
+
+// NewXOFSize returns a new extended output function with the given output
+// size and no key. If size is zero, output size is UnknownSize.
+func NewXOFSize(size int) (*XOF, error) {
+	if !validSize(size) {
+		return nil, ErrSize
+	}
+	return NewXOF(&Config{Size: uint16(size)})
+}
+
+// Sum computes the XOF of data with output size len(out) and puts the
+// result into out. Config may be nil. If config size is not zero,
+// it must be equal to len(out).
+func Sum(out, data []byte, c *Config) error {
+	if !validSize(len(out)) {
+		return ErrSize
+	}
+	var cc Config
+	if c != nil {
+		if c.Size != 0 && int(c.Size) != len(out) {
+			return ErrSizeMismatch
+		}
+		cc = *c
+	}
+	cc.Size = uint16(len(out))
+	x, err := NewXOF(&cc)
+	if err != nil {
+		return err
+	}
+	if _, err := x.Write(data); err != nil {
+		return err
+	}
+	_, err = io.ReadFull(x, out)
+	return err
+}
+
+// SumBatch computes the XOF of each inputs[i] with output size len(out[i])
+// and puts the result into out[i], reusing the root hash between inputs.
+// Config may be nil. If config size is not zero, it must be equal to the
+// length of each output. Out and inputs must have the same length.
+func SumBatch(out, inputs [][]byte, c *Config) error {
+	if len(out) != len(inputs) {
+		return ErrBatchLength
+	}
+	var x *XOF
+	for i, data := range inputs {
+		size := len(out[i])
+		if !validSize(size) {
+			return ErrSize
+		}
+		if c != nil && c.Size != 0 && int(c.Size) != size {
+			return ErrSizeMismatch
+		}
+		if size == 0 {
+			size = UnknownSize
+		}
+		if x == nil {
+			var cc Config
+			if c != nil {
+				cc = *c
+			}
+			cc.Size = uint16(size)
+			var err error
+			if x, err = NewXOF(&cc); err != nil {
+				return err
+			}
+		} else {
+			x.Reset()
+			if size != x.size {
+				if err := x.SetSize(size); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := x.Write(data); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(x, out[i]); err != nil {
+			return err
+		}
 	}
-	return x.rh.Write(p)
+	return nil
+}
+
+// Size returns the output size of XOF.
+func (x *XOF) Size() int { return x.size }
+
+// Remaining returns the number of output bytes left to read.
+func (x *XOF) Remaining() int { return x.left }
+
+// Reset resets the XOF to its initial state, as returned by NewXOF,
+// keeping its configuration.
+func (x *XOF) Reset() {
+	x.rh.Reset()
+	x.n = 0
+	x.wrote = false
+	x.stale = false
+	x.resetOutput()
+	x.Flush() // can't fail at the start of output
 }
 
-func (x *xof) Read(p []byte) (nn int, err error) {
-	if x.h0 == nil {
+// Rewind discards output and returns the XOF to writing, keeping the input
+// written so far, so that more input can be written. Output read before
+// Rewind is invalidated: after writing more input, output starts from the
+// beginning and is the output for all input written.
+//
+// Rewind returns ErrNoRootState if the root hash state is not available,
+// which is the case after unmarshaling a finalized state, or cloning
+// a finalized XOF whose root hash doesn't support marshaling.
+func (x *XOF) Rewind() error {
+	if x.stale {
+		return ErrNoRootState
+	}
+	x.x = [blake2s.Size]byte{}
+	x.resetOutput()
+	return nil
+}
+
+// ResetWithKey resets the XOF to its initial state, as returned by NewXOF,
+// with the same configuration, but with the given key. Key may be nil.
+func (x *XOF) ResetWithKey(key []byte) error {
+	if len(key) > blake2s.Size {
+		return ErrKeySize
+	}
+	rc := x.rc
+	rc.Key = append([]byte(nil), key...)
+	rh, err := newHash(x.nh, &rc)
+	if err != nil {
+		return err
+	}
+	for i := range x.rc.Key {
+		x.rc.Key[i] = 0
+	}
+	x.rh = rh
+	x.rc = rc
+	x.n = 0
+	x.wrote = false
+	x.stale = false
+	x.resetOutput()
+	x.Flush() // can't fail at the start of output
+	return nil
+}
+
+// SetSize changes the output size of XOF. If size is zero, output size is
+// UnknownSize. Since output size is a parameter of the root hash, SetSize
+// must be called before writing any input or reading any output; otherwise
+// it returns ErrSetSize.
+func (x *XOF) SetSize(size int) error {
+	if !validSize(size) {
+		return ErrSize
+	}
+	if size == 0 {
+		size = UnknownSize
+	}
+	if x.fin || x.n != 0 {
+		return ErrSetSize
+	}
+	// Replace trees rather than modifying them, since they may be
+	// shared with clones.
+	const mask = 1<<32 - 1
+	rt := *x.rc.Tree
+	rt.NodeOffset = rt.NodeOffset&mask + uint64(size)<<32
+	ot := *x.oc.Tree
+	ot.NodeOffset = uint64(size) << 32
+	rc := x.rc
+	rc.Tree = &rt
+	rh, err := newHash(x.nh, &rc)
+	if err != nil {
+		return err
+	}
+	x.rh = rh
+	x.rc = rc
+	x.oc.Tree = &ot
+	x.size = size
+	x.left = size
+	return nil
+}
+
+// Clone returns an independent copy of the XOF in its current state.
+//
+// If the root hash is not finalized yet, its state is copied, which requires
+// it to implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+func (x *XOF) Clone() (*XOF, error) {
+	rh, err := newHash(x.nh, &x.rc)
+	if err != nil {
+		return nil, err
+	}
+	// Without marshaling, finalized clone gets a fresh root hash.
+	stale := true
+	if m, ok := x.rh.(encoding.BinaryMarshaler); ok {
+		state, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		u, ok := rh.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return nil, ErrNoMarshal
+		}
+		if err := u.UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+		stale = x.stale
+	} else if !x.fin {
+		return nil, ErrNoMarshal
+	}
+	c := &XOF{
+		rh:     rh,
+		nh:     x.nh,
+		rc:     x.rc,
+		oc:     x.oc,
+		h0:     x.h0,
+		fin:    x.fin,
+		x:      x.x,
+		px:     x.px,
+		left:   x.left,
+		next:   x.next,
+		size:   x.size,
+		n:      x.n,
+		min:    x.min,
+		err:    x.err,
+		wrote:  x.wrote,
+		strict: x.strict,
+		tap:    x.tap,
+		bp:     x.bp,
+		full:   x.full,
+		bufLen: x.bufLen,
+		stale:  stale,
+	}
+	// Copy key, so that Clear doesn't wipe it from clones.
+	c.rc.Key = append([]byte(nil), x.rc.Key...)
+	return c, nil
+}
+
+// Clear overwrites the root digest, output buffers and the copy of key with
+// zeros. The XOF must not be used after calling Clear.
+//
+// Clear doesn't wipe the internal state of the root hash, which is
+// not accessible.
+func (x *XOF) Clear() {
+	for i := range x.rc.Key {
+		x.rc.Key[i] = 0
+	}
+	x.h0 = [blake2s.Size]byte{}
+	x.x = [blake2s.Size]byte{}
+	for i := range x.buf {
+		x.buf[i] = 0
+	}
+	x.fin = true
+	x.px = blake2s.Size
+	x.left = 0
+}
+
+// Flush overwrites the output buffers with zeros without changing the
+// position. If the position is inside an output block, the unread part of
+// this block is computed again and kept in memory, since it's needed for
+// reading; only the bytes already read are overwritten. If computing the
+// block fails, Flush returns the error and leaves the output buffer as is.
+func (x *XOF) Flush() error {
+	for i := range x.buf {
+		x.buf[i] = 0
+	}
+	if x.fin && x.px < blake2s.Size && x.left > 0 {
+		if err := x.setPos(int64(x.size - x.left)); err != nil {
+			return err
+		}
+		for i := 0; i < x.px; i++ {
+			x.x[i] = 0
+		}
+		return nil
+	}
+	x.x = [blake2s.Size]byte{}
+	return nil
+}
+
+// resetOutput clears the root digest and rewinds output to the beginning.
+func (x *XOF) resetOutput() {
+	x.h0 = [blake2s.Size]byte{}
+	x.fin = false
+	x.err = nil
+	x.px = blake2s.Size
+	x.left = x.size
+	x.next = 0
+}
+
+func (x *XOF) Write(p []byte) (nn int, err error) {
+	if x.fin {
+		return 0, ErrWriteAfterRead
+	}
+	x.wrote = true
+	return x.absorb(p)
+}
+
+// WriteFramed writes the length of p as 8-byte big-endian integer, followed
+// by p. Unlike plain writes, a sequence of framed writes can't produce the
+// same input for different sequences of p. It returns the number of bytes
+// of p written, not including the length.
+func (x *XOF) WriteFramed(p []byte) (nn int, err error) {
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(p)))
+	if _, err := x.Write(l[:]); err != nil {
+		return 0, err
+	}
+	return x.Write(p)
+}
+
+// WriteString is like Write, but writes the contents of string s.
+// It implements io.StringWriter.
+func (x *XOF) WriteString(s string) (nn int, err error) {
+	if x.fin {
+		return 0, ErrWriteAfterRead
+	}
+	x.wrote = true
+	if sw, ok := x.rh.(io.StringWriter); ok && x.tap == nil {
+		nn, err = sw.WriteString(s)
+		x.n += int64(nn)
+		return nn, err
+	}
+	// Output buffer is unused while absorbing, so pass
+	// the string through it to avoid allocation.
+	for len(s) > 0 {
+		n := copy(x.x[:], s)
+		n, err := x.absorb(x.x[:n])
+		nn += n
+		if err != nil {
+			return nn, err
+		}
+		s = s[n:]
+	}
+	return nn, nil
+}
+
+// ReadFrom reads data from r until io.EOF and writes it into the root hash.
+// It returns the number of bytes read. It implements io.ReaderFrom.
+func (x *XOF) ReadFrom(r io.Reader) (n int64, err error) {
+	if x.fin {
+		return 0, ErrWriteAfterRead
+	}
+	x.wrote = true
+	buf := x.scratch()
+	for {
+		nr, rerr := r.Read(buf)
+		if nr > 0 {
+			nw, err := x.absorb(buf[:nr])
+			n += int64(nw)
+			if err != nil {
+				return n, err
+			}
+		}
+		if rerr == io.EOF {
+			return n, nil
+		}
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+}
+
+// AbsorbAll writes input from sources into x in order. Each source must be
+// []byte, string, or io.Reader, which is read until io.EOF. It stops at the
+// first error, returning ErrSourceType for unsupported sources.
+func AbsorbAll(x *XOF, sources ...interface{}) error {
+	for _, src := range sources {
+		var err error
+		switch v := src.(type) {
+		case []byte:
+			_, err = x.Write(v)
+		case string:
+			_, err = x.WriteString(v)
+		case io.Reader:
+			_, err = x.ReadFrom(v)
+		default:
+			err = ErrSourceType
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// absorb writes p into the root hash and the tap.
+func (x *XOF) absorb(p []byte) (nn int, err error) {
+	nn, err = x.rh.Write(p)
+	x.n += int64(nn)
+	if err != nil || x.tap == nil {
+		return nn, err
+	}
+	tn, err := x.tap.Write(p[:nn])
+	if err == nil && tn != nn {
+		err = io.ErrShortWrite
+	}
+	return nn, err
+}
+
+// finalize computes the root digest if it wasn't computed yet.
+// It returns ErrShortInput if fewer than the minimum number of input
+// bytes were written.
+func (x *XOF) finalize() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.fin {
+		if x.strict && !x.wrote {
+			x.err = ErrNoInput
+		} else if x.n < x.min {
+			x.err = ErrShortInput
+		}
 		// Get root digest
-		x.h0 = x.rh.Sum(nil)
+		x.rh.Sum(x.h0[:0])
+		x.fin = true
+	}
+	return x.err
+}
+
+// Bytes finalizes the root hash and returns the whole output of Size bytes
+// in a new slice. It returns ErrSize for XOF of UnknownSize. Like Sum, it
+// doesn't change the position of Read.
+func (x *XOF) Bytes() ([]byte, error) {
+	if x.size == UnknownSize {
+		return nil, ErrSize
+	}
+	b := make([]byte, x.size)
+	if _, err := x.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Sum32 finalizes the root hash and returns the first 32 bytes of output.
+// It returns ErrSize if output size is smaller than 32 bytes. Like Sum, it
+// doesn't change the position of Read.
+func (x *XOF) Sum32() (out [32]byte, err error) {
+	if x.size < len(out) {
+		return out, ErrSize
+	}
+	_, err = x.ReadAt(out[:], 0)
+	return out, err
+}
+
+// Sum64 is like Sum32, but returns the first 64 bytes of output.
+func (x *XOF) Sum64() (out [64]byte, err error) {
+	if x.size < len(out) {
+		return out, ErrSize
+	}
+	_, err = x.ReadAt(out[:], 0)
+	return out, err
+}
+
+// Finalized reports whether the root hash is finalized, that is, whether
+// reading has begun and no more input can be written. It doesn't finalize.
+func (x *XOF) Finalized() bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.fin
+}
+
+// Finalize finalizes the root hash and checks that output hashes can be
+// created, returning an error from the backend if they can't. Writing is not
+// allowed after calling Finalize, just as after Read.
+func (x *XOF) Finalize() error {
+	if err := x.finalize(); err != nil {
+		return err
+	}
+	tree := *x.oc.Tree
+	c := x.oc
+	c.Tree = &tree
+	_, err := newHash(x.nh, &c)
+	return err
+}
+
+// RootDigest finalizes the root hash and returns a copy of the root digest,
+// which is the input to output hashes. Writing is not allowed after calling
+// RootDigest, just as after Read.
+func (x *XOF) RootDigest() ([]byte, error) {
+	if err := x.finalize(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), x.h0[:]...), nil
+}
+
+// Sum finalizes the root hash and appends the whole output of Size bytes
+// to b, returning the resulting slice. For UnknownSize, it appends
+// UnknownSize bytes. Sum doesn't change the position of Read, so it can be
+// called repeatedly and mixed with reads.
+//
+// Sum panics if finalization fails, for example, because of MinInput or
+// Strict, or if the backend returns an error. Use Finalize before Sum or
+// use Bytes to get the error instead.
+func (x *XOF) Sum(b []byte) []byte {
+	n := len(b)
+	b = append(b, make([]byte, x.size)...)
+	if _, err := x.ReadAt(b[n:], 0); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Child finalizes the root hash and returns a new XOF of the given size,
+// whose output depends on the root digest and label. If size is zero,
+// output size is UnknownSize. Children with different labels are
+// independent of each other and of the parent output.
+//
+// The child is the XOF with Key set to the root digest, Person set to label
+// encoded as 8-byte little-endian integer, no salt, and no input. It uses
+// the same backend as the parent.
+func (x *XOF) Child(label uint64, size int) (*XOF, error) {
+	if !validSize(size) {
+		return nil, ErrSize
 	}
-	for i := range p {
-		if x.left == 0 && i != len(p) {
+	if err := x.finalize(); err != nil {
+		return nil, err
+	}
+	var person [8]byte
+	binary.LittleEndian.PutUint64(person[:], label)
+	c, err := NewXOF(&Config{
+		Size:    uint16(size),
+		Key:     x.h0[:],
+		Person:  person[:],
+		Backend: x.nh,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.finalize()
+	return c, nil
+}
+
+// Read reads up to len(p) bytes of output into p. It finalizes the root hash.
+// If output ends before p is filled, Read returns the number of bytes read
+// and io.EOF. A Read that ends exactly at the end of output returns nil error,
+// and the next one returns io.EOF.
+func (x *XOF) Read(p []byte) (nn int, err error) {
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	for nn < len(p) {
+		if x.left == 0 {
+			// Output is exhausted.
 			return nn, io.EOF
 		}
 		if x.px >= blake2s.Size {
 			// Refill buffer.
-			if x.left < blake2s.Size {
-				// This is the last block.
-				x.oc.Size = uint8(x.left)
+			if _, err := x.block(x.x[:], x.next); err != nil {
+				return nn, err
 			}
-			h, err := blake2s.New(&x.oc)
+			x.next++
+			x.px = 0
+		}
+		n := copy(p[nn:], x.x[x.px:])
+		if n > x.left {
+			n = x.left
+		}
+		x.px += n
+		x.left -= n
+		nn += n
+	}
+	return nn, nil
+}
+
+// Absorb is the same as Write.
+func (x *XOF) Absorb(p []byte) (nn int, err error) { return x.Write(p) }
+
+// Squeeze is the same as Read.
+func (x *XOF) Squeeze(p []byte) (nn int, err error) { return x.Read(p) }
+
+// Fill reads exactly len(buf) bytes of output into buf. If fewer bytes
+// are left, it returns io.ErrUnexpectedEOF.
+func (x *XOF) Fill(buf []byte) error {
+	_, err := x.Read(buf)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// HexString reads n bytes of output and returns them hex-encoded.
+// If fewer bytes are left, it returns io.ErrUnexpectedEOF.
+func (x *XOF) HexString(n int) (string, error) {
+	b, err := x.readN(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Base64String reads n bytes of output and returns them encoded with
+// standard base64 encoding. If fewer bytes are left, it returns
+// io.ErrUnexpectedEOF.
+func (x *XOF) Base64String(n int) (string, error) {
+	b, err := x.readN(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// readN reads exactly n bytes of output into a new slice.
+func (x *XOF) readN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	b := make([]byte, n)
+	if err := x.Fill(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadFull reads exactly len(p) bytes of output into p, like io.ReadFull.
+// It returns the number of bytes read. The error is io.EOF only if no bytes
+// were left, and io.ErrUnexpectedEOF if output ended before p was filled.
+func (x *XOF) ReadFull(p []byte) (nn int, err error) { return io.ReadFull(x, p) }
+
+// Limit returns a reader, which reads at most n bytes of output from the XOF
+// and then returns io.EOF. It can't read past the end of output.
+func (x *XOF) Limit(n int64) io.Reader { return io.LimitReader(x, n) }
+
+// ReadContext is like Read, but checks ctx before generating each output
+// block. If ctx is done, it returns the number of bytes read so far and
+// ctx.Err().
+func (x *XOF) ReadContext(ctx context.Context, p []byte) (nn int, err error) {
+	for nn < len(p) {
+		if err := ctx.Err(); err != nil {
+			return nn, err
+		}
+		// Read up to the end of the current block.
+		n := blake2s.Size - x.px
+		if n == 0 {
+			n = blake2s.Size
+		}
+		if n > len(p)-nn {
+			n = len(p) - nn
+		}
+		m, err := x.Read(p[nn : nn+n])
+		nn += m
+		if err != nil {
+			return nn, err
+		}
+	}
+	return nn, nil
+}
+
+// ReadByte reads and returns the next byte of output.
+func (x *XOF) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := x.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// block computes output block i into dst, which must have room for
+// blake2s.Size bytes, and returns the length of the block.
+// The root digest must be finalized.
+//
+// Each block is a separate hash with its own parameter block, which
+// includes the node offset, so it must be created anew from the output
+// config template: the state after absorbing h0 can't be shared between
+// blocks. For the same reason hashes can't be pooled: blake2s has no way
+// to reinitialize an instance with different parameters.
+func (x *XOF) block(dst []byte, i int) (int, error) {
+	n := x.size - i*blake2s.Size
+	if n > blake2s.Size {
+		n = blake2s.Size
+	}
+	tree := *x.oc.Tree
+	tree.NodeOffset = uint64(x.size)<<32 + uint64(i)
+	person := x.oc.Person
+	if x.bp != nil {
+		person = x.bp(uint64(i))
+	}
+	oc := x.oc
+	oc.Size = uint8(n)
+	if x.full {
+		oc.Size = blake2s.Size
+	}
+	oc.Person = person
+	oc.Tree = &tree
+	var h hash.Hash
+	var err error
+	if x.nh == nil {
+		h, err = blake2s.New(&oc)
+	} else {
+		// Backend gets its own copies, built from x.oc rather than
+		// oc, so that oc and tree stay on stack for blake2s.New.
+		t := new(blake2s.Tree)
+		*t = *x.oc.Tree
+		t.NodeOffset = tree.NodeOffset
+		c := new(blake2s.Config)
+		*c = x.oc
+		c.Size = oc.Size
+		c.Person = person
+		c.Tree = t
+		h, err = x.nh(c)
+	}
+	if err != nil {
+		return 0, err
+	}
+	h.Write(x.h0[:])
+	if int(oc.Size) > len(dst) {
+		var t [blake2s.Size]byte
+		h.Sum(t[:0])
+		copy(dst, t[:n])
+		return n, nil
+	}
+	h.Sum(dst[:0])
+	return n, nil
+}
+
+// ReadBlockAt finalizes the root hash and puts the output block with the
+// given index into dst. Dst must be BlockSize bytes long, or as long as
+// the last block if it's shorter. It doesn't change the position of Read.
+func (x *XOF) ReadBlockAt(dst []byte, blockIndex uint64) error {
+	if blockIndex >= uint64((x.size+BlockSize-1)/BlockSize) {
+		return ErrBlockIndex
+	}
+	i := int(blockIndex)
+	n := x.size - i*BlockSize
+	if n > BlockSize {
+		n = BlockSize
+	}
+	if len(dst) != n {
+		return ErrBlockLength
+	}
+	if err := x.finalize(); err != nil {
+		return err
+	}
+	_, err := x.block(dst, i)
+	return err
+}
+
+// NextBlock generates the next output block directly into dst and returns
+// its length, which is BlockSize for all blocks except the last one.
+// Dst must be at least BlockSize bytes long. The current position must be
+// at a block boundary, otherwise NextBlock returns ErrBlockAlign. After the
+// last block, it returns io.EOF.
+func (x *XOF) NextBlock(dst []byte) (int, error) {
+	if len(dst) < BlockSize {
+		return 0, ErrBlockLength
+	}
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	if x.left == 0 {
+		return 0, io.EOF
+	}
+	if x.px < blake2s.Size {
+		return 0, ErrBlockAlign
+	}
+	n, err := x.block(dst, x.next)
+	if err != nil {
+		return 0, err
+	}
+	x.next++
+	x.left -= n
+	return n, nil
+}
+
+// newHash creates a hash with backend b, or blake2s.New if b is nil.
+func newHash(b Backend, c *blake2s.Config) (hash.Hash, error) {
+	if b == nil {
+		return blake2s.New(c)
+	}
+	return b(c)
+}
+
+// ReadAt reads len(p) bytes of output starting at offset off. It finalizes
+// the root hash, but doesn't change the position used by Read. ReadAt is
+// safe to call concurrently from multiple goroutines.
+func (x *XOF) ReadAt(p []byte, off int64) (nn int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if off >= int64(x.size) {
+		return 0, io.EOF
+	}
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	var buf [blake2s.Size]byte
+	i := int(off / blake2s.Size)
+	pos := int(off % blake2s.Size)
+	for nn < len(p) {
+		if i*blake2s.Size >= x.size {
+			return nn, io.EOF
+		}
+		n, err := x.block(buf[:], i)
+		if err != nil {
+			return nn, err
+		}
+		nn += copy(p[nn:], buf[pos:n])
+		pos = 0
+		i++
+	}
+	return nn, nil
+}
+
+// Peek returns the next n bytes of output without advancing the position.
+// If fewer than n bytes are left, it returns them and io.EOF.
+// The returned slice is a copy.
+func (x *XOF) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	b := make([]byte, n)
+	if n == 0 {
+		return b, nil
+	}
+	nn, err := x.ReadAt(b, int64(x.size-x.left))
+	return b[:nn], err
+}
+
+// Seek sets the position for the next Read to offset, interpreted according
+// to whence, and returns the new position. It finalizes the root hash.
+// Seeking past the end of output is allowed: the position is set to Size,
+// which is returned, and the next Read returns io.EOF. Seeking before the
+// start returns ErrNegativePosition, and the position is not changed.
+func (x *XOF) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = int64(x.size-x.left) + offset
+	case io.SeekEnd:
+		abs = int64(x.size) + offset
+	default:
+		return 0, ErrInvalidWhence
+	}
+	if whence != io.SeekStart && offset > 0 && abs < 0 {
+		return 0, ErrSeekOverflow
+	}
+	if abs < 0 {
+		return 0, ErrNegativePosition
+	}
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	if abs > int64(x.size) {
+		abs = int64(x.size)
+	}
+	if err := x.setPos(abs); err != nil {
+		return 0, err
+	}
+	return abs, nil
+}
+
+// setPos sets the position of the next output byte to pos.
+// The root digest must be finalized.
+func (x *XOF) setPos(pos int64) error {
+	if pos >= int64(x.size) {
+		x.left = 0
+		x.px = blake2s.Size
+		return nil
+	}
+	i := int(pos / blake2s.Size)
+	if r := int(pos % blake2s.Size); r != 0 {
+		// Fill buffer with the block containing pos. The position
+		// is changed only if it succeeds.
+		var buf [blake2s.Size]byte
+		if _, err := x.block(buf[:], i); err != nil {
+			return err
+		}
+		x.x = buf
+		x.next = i + 1
+		x.left = x.size - int(pos)
+		x.px = r
+		return nil
+	}
+	x.next = i
+	x.left = x.size - int(pos)
+	x.px = blake2s.Size
+	return nil
+}
+
+// ReadParallel is like Read, but generates whole output blocks using the
+// given number of goroutines. If workers is less than 1, GOMAXPROCS
+// goroutines are used. The output is the same as produced by Read.
+func (x *XOF) ReadParallel(p []byte, workers int) (nn int, err error) {
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	if x.px < blake2s.Size {
+		// Use up the buffer to start at the block boundary.
+		n := blake2s.Size - x.px
+		if n > len(p) {
+			n = len(p)
+		}
+		nn, err = x.Read(p[:n])
+		if err != nil || nn == len(p) {
+			return nn, err
+		}
+	}
+	avail := len(p) - nn
+	if avail > x.left {
+		avail = x.left
+	}
+	if count := avail / blake2s.Size; count > 0 {
+		if workers < 1 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		if workers > count {
+			workers = count
+		}
+		pos := x.size - x.left
+		first := pos / blake2s.Size
+		var wg sync.WaitGroup
+		errs := make([]error, workers)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for k := w; k < count; k += workers {
+					off := nn + k*blake2s.Size
+					if _, err := x.block(p[off:off+blake2s.Size], first+k); err != nil {
+						errs[w] = err
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		for _, err := range errs {
 			if err != nil {
 				return nn, err
 			}
-			h.Write(x.h0)
-			h.Sum(x.x[:0])
-			x.oc.Tree.NodeOffset++
-			x.px = 0
 		}
-		p[i] = x.x[x.px]
-		x.px++
-		x.left--
-		nn++
+		if err := x.setPos(int64(pos + count*blake2s.Size)); err != nil {
+			return nn, err
+		}
+		nn += count * blake2s.Size
+	}
+	if nn < len(p) {
+		// Read the rest sequentially.
+		n, err := x.Read(p[nn:])
+		nn += n
+		return nn, err
+	}
+	return nn, nil
+}
+
+// Discard skips the next n bytes of output and returns the number of bytes
+// discarded. If fewer than n bytes are left, it discards them and returns
+// io.EOF. Only the block Discard stops in is computed.
+func (x *XOF) Discard(n int) (discarded int, err error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+	if err := x.finalize(); err != nil {
+		return 0, err
+	}
+	discarded = n
+	if discarded > x.left {
+		discarded, err = x.left, io.EOF
+	}
+	if x.px+discarded < blake2s.Size {
+		// Stay within the buffer.
+		x.px += discarded
+		x.left -= discarded
+		return discarded, err
+	}
+	if perr := x.setPos(int64(x.size - x.left + discarded)); perr != nil {
+		return 0, perr
+	}
+	return discarded, err
+}
+
+// WriteTo writes all remaining output to w. For UnknownSize, this is
+// at most UnknownSize bytes. It implements io.WriterTo.
+func (x *XOF) WriteTo(w io.Writer) (n int64, err error) {
+	return x.writeN(w, int64(x.left))
+}
+
+// WriteN writes the next n bytes of output to w. If fewer than n bytes
+// are left, it writes them and returns io.EOF.
+func (x *XOF) WriteN(w io.Writer, n int64) (written int64, err error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+	if n > int64(x.left) {
+		written, err = x.writeN(w, int64(x.left))
+		if err == nil {
+			err = io.EOF
+		}
+		return written, err
+	}
+	return x.writeN(w, n)
+}
+
+// roundBufferSize returns the size of scratch buffer for the configured
+// size n: the default if it's zero, otherwise n rounded up to a multiple
+// of BlockSize.
+func roundBufferSize(n int) int {
+	if n == 0 {
+		return bufferSize
+	}
+	return (n + BlockSize - 1) / BlockSize * BlockSize
+}
+
+// scratch returns the scratch buffer, allocating it if needed.
+func (x *XOF) scratch() []byte {
+	if x.buf == nil {
+		x.buf = make([]byte, roundBufferSize(x.bufLen))
+	}
+	return x.buf
+}
+
+// writeN writes n bytes of output to w, which must not exceed x.left.
+func (x *XOF) writeN(w io.Writer, n int64) (written int64, err error) {
+	if n == 0 {
+		return 0, nil
+	}
+	buf := x.scratch()
+	for written < n {
+		chunk := buf
+		if int64(len(chunk)) > n-written {
+			chunk = chunk[:n-written]
+		}
+		nr, err := x.Read(chunk)
+		if err != nil {
+			return written, err
+		}
+		nw, err := w.Write(chunk[:nr])
+		written += int64(nw)
+		if err != nil {
+			return written, err
+		}
+		if nw != nr {
+			return written, io.ErrShortWrite
+		}
+	}
+	return written, nil
+}
+
+type concurrentReader struct {
+	mu sync.Mutex
+	x  *XOF
+}
+
+// NewConcurrentReader returns a reader, which reads output from x and is
+// safe to use from multiple goroutines. Reads are serialized: each one
+// returns consecutive output that doesn't overlap with other reads.
+func NewConcurrentReader(x *XOF) io.Reader {
+	return &concurrentReader{x: x}
+}
+
+func (r *concurrentReader) Read(p []byte) (nn int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.x.Read(p)
+}
+
+// EqualReader reads n bytes of output and n bytes from r in chunks, and
+// reports whether they are equal. It stops at the first difference. If
+// either output or r ends before n bytes, it returns io.ErrUnexpectedEOF.
+func (x *XOF) EqualReader(r io.Reader, n int) (bool, error) {
+	if n < 0 {
+		return false, ErrNegativeCount
+	}
+	var a, b [bufferSize]byte
+	for n > 0 {
+		m := n
+		if m > len(a) {
+			m = len(a)
+		}
+		if err := x.Fill(a[:m]); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(r, b[:m]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return false, err
+		}
+		if !bytes.Equal(a[:m], b[:m]) {
+			return false, nil
+		}
+		n -= m
+	}
+	return true, nil
+}
+
+// Pipe starts a goroutine, which writes the remaining output into a pipe,
+// and returns the read end of the pipe and a function to stop it.
+// The goroutine exits after writing all output, or when the returned
+// function is called. The XOF must not be used until then.
+//
+// Calling the stop function closes the pipe and waits for the goroutine
+// to exit. It is safe to call it multiple times.
+func (x *XOF) Pipe() (io.Reader, func()) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := x.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	return pr, func() {
+		pr.Close()
+		<-done
 	}
-	return nn, err
 }
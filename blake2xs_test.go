@@ -2,9 +2,30 @@ package blake2xs
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"hash"
 	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+
+	"github.com/dchest/blake2s"
+)
+
+var (
+	_ io.ReadWriter   = (*XOF)(nil)
+	_ io.ReaderAt     = (*XOF)(nil)
+	_ io.Seeker       = (*XOF)(nil)
+	_ io.ReadSeeker   = (*XOF)(nil)
+	_ io.ByteReader   = (*XOF)(nil)
+	_ io.WriterTo     = (*XOF)(nil)
+	_ io.StringWriter = (*XOF)(nil)
+	_ io.ReaderFrom   = (*XOF)(nil)
 )
 
 func TestRead(t *testing.T) {
@@ -65,6 +86,2054 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+func TestReadAt(t *testing.T) {
+	for _, size := range []uint16{1, 31, 32, 33, 100, 1024, UnknownSize} {
+		h, _ := NewXOF(&Config{Size: size})
+		h.Write([]byte{1, 2, 3})
+		want := make([]byte, size)
+		if _, err := h.Read(want); err != nil {
+			t.Fatalf("size %d: error reading: %s", size, err)
+		}
+		for _, off := range []int{0, 1, 31, 32, 33, 64, 99, int(size) - 1} {
+			if off < 0 || off >= int(size) {
+				continue
+			}
+			for _, ln := range []int{0, 1, 5, 32, 33, 100} {
+				b := make([]byte, ln)
+				n, err := h.ReadAt(b, int64(off))
+				exp := ln
+				if off+ln > int(size) {
+					exp = int(size) - off
+					if err != io.EOF {
+						t.Errorf("size %d, off %d, len %d: expected io.EOF, got %v", size, off, ln, err)
+					}
+				} else if err != nil {
+					t.Errorf("size %d, off %d, len %d: error: %s", size, off, ln, err)
+				}
+				if n != exp {
+					t.Errorf("size %d, off %d, len %d: expected n = %d, got %d", size, off, ln, exp, n)
+				}
+				if !bytes.Equal(b[:n], want[off:off+n]) {
+					t.Errorf("size %d, off %d, len %d: output mismatch", size, off, ln)
+				}
+			}
+		}
+		n, err := h.ReadAt(make([]byte, 1), int64(size))
+		if n != 0 || err != io.EOF {
+			t.Errorf("size %d: expected (0, io.EOF) past end, got (%d, %v)", size, n, err)
+		}
+		if _, err := h.ReadAt(make([]byte, 1), -1); err != ErrNegativeOffset {
+			t.Errorf("size %d: expected error for negative offset", size)
+		}
+	}
+}
+
+func TestReadAtConcurrent(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 4096})
+	h.Write([]byte{1, 2, 3})
+	want := make([]byte, 4096)
+	h.ReadAt(want, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(off int) {
+			defer wg.Done()
+			b := make([]byte, 100)
+			n, err := h.ReadAt(b, int64(off))
+			if n != len(b) || err != nil {
+				t.Errorf("off %d: error reading: %v (n = %d)", off, err, n)
+			}
+			if !bytes.Equal(b, want[off:off+len(b)]) {
+				t.Errorf("off %d: output mismatch", off)
+			}
+		}(i * 500)
+	}
+	wg.Wait()
+	// ReadAt must not advance the Read cursor.
+	b := make([]byte, 4096)
+	if _, err := io.ReadFull(h, b); err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Read output differs from ReadAt output")
+	}
+}
+
+func TestSeek(t *testing.T) {
+	for _, size := range []uint16{1, 31, 32, 33, 100, 1024} {
+		h, _ := NewXOF(&Config{Size: size})
+		h.Write([]byte{1, 2, 3})
+		want := make([]byte, size)
+		h.ReadAt(want, 0)
+		for _, off := range []int64{int64(size) - 1, 0, 33, 1, 32, 64, 31, 99} {
+			if off >= int64(size) {
+				continue
+			}
+			pos, err := h.Seek(off, io.SeekStart)
+			if pos != off || err != nil {
+				t.Fatalf("size %d: Seek(%d) returned (%d, %v)", size, off, pos, err)
+			}
+			b := make([]byte, int64(size)-off)
+			n, err := io.ReadFull(h, b)
+			if n != len(b) || err != nil {
+				t.Errorf("size %d, off %d: error reading: %v (n = %d)", size, off, err, n)
+			}
+			if !bytes.Equal(b, want[off:]) {
+				t.Errorf("size %d, off %d: output mismatch", size, off)
+			}
+		}
+		// Relative seeks.
+		b := make([]byte, 1)
+		if size > 2 {
+			h.Seek(0, io.SeekStart)
+			h.Read(make([]byte, 3))
+			if pos, _ := h.Seek(-2, io.SeekCurrent); pos != 1 {
+				t.Errorf("size %d: SeekCurrent: expected 1, got %d", size, pos)
+			}
+			if _, err := h.Read(b); err != nil || b[0] != want[1] {
+				t.Errorf("size %d: SeekCurrent: wrong output", size)
+			}
+		}
+		if pos, _ := h.Seek(-1, io.SeekEnd); pos != int64(size)-1 {
+			t.Errorf("size %d: SeekEnd: expected %d, got %d", size, size-1, pos)
+		}
+		if _, err := h.Read(b); err != nil || b[0] != want[size-1] {
+			t.Errorf("size %d: SeekEnd: wrong output", size)
+		}
+		// Past the end.
+		if pos, err := h.Seek(int64(size)+10, io.SeekStart); pos != int64(size) || err != nil {
+			t.Errorf("size %d: seeking past end: expected (%d, nil), got (%d, %v)", size, size, pos, err)
+		}
+		if pos, _ := h.Seek(0, io.SeekCurrent); pos != int64(size) {
+			t.Errorf("size %d: expected position %d after seeking past end, got %d", size, size, pos)
+		}
+		if n, err := h.Read(b); n != 0 || err != io.EOF {
+			t.Errorf("size %d: expected (0, io.EOF) after seeking past end, got (%d, %v)", size, n, err)
+		}
+		if _, err := h.Seek(-1, io.SeekStart); err != ErrNegativePosition {
+			t.Errorf("size %d: expected error for negative position", size)
+		}
+	}
+}
+
+func TestReadParallel(t *testing.T) {
+	for _, size := range []uint16{1, 32, 33, 100, 1024, UnknownSize} {
+		h, _ := NewXOF(&Config{Size: size})
+		h.Write([]byte{1, 2, 3})
+		want := make([]byte, size)
+		h.ReadAt(want, 0)
+		for _, workers := range []int{0, 1, 3, 8} {
+			for _, start := range []int64{0, 1, 32, 45} {
+				if start >= int64(size) {
+					continue
+				}
+				h.Seek(start, io.SeekStart)
+				b := make([]byte, int(size)-int(start))
+				n, err := h.ReadParallel(b, workers)
+				if n != len(b) || err != nil {
+					t.Errorf("size %d, workers %d, start %d: error reading: %v (n = %d)", size, workers, start, err, n)
+				}
+				if !bytes.Equal(b, want[start:]) {
+					t.Errorf("size %d, workers %d, start %d: output mismatch", size, workers, start)
+				}
+				if n, err := h.Read(b[:1]); n != 0 || err != io.EOF {
+					t.Errorf("size %d, workers %d, start %d: expected io.EOF", size, workers, start)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkRead64K(b *testing.B) {
+	buf := make([]byte, UnknownSize)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h, _ := NewXOF(nil)
+		h.Read(buf)
+	}
+}
+
+func BenchmarkReadParallel64K(b *testing.B) {
+	buf := make([]byte, UnknownSize)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h, _ := NewXOF(nil)
+		h.ReadParallel(buf, 0)
+	}
+}
+
+func TestReadChunks(t *testing.T) {
+	for _, size := range []uint16{1, 31, 32, 33, 63, 65, 95, 97, 100, 1024} {
+		h, _ := NewXOF(&Config{Size: size})
+		h.Write([]byte{1, 2, 3})
+		want := make([]byte, size)
+		h.Read(want)
+		for _, chunk := range []int{1, 5, 7, 31, 32, 33, 64, 100} {
+			h, _ := NewXOF(&Config{Size: size})
+			h.Write([]byte{1, 2, 3})
+			var got []byte
+			b := make([]byte, chunk)
+			for {
+				n, err := h.Read(b)
+				got = append(got, b[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("size %d, chunk %d: error reading: %s", size, chunk, err)
+				}
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("size %d, chunk %d: output mismatch", size, chunk)
+			}
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := &Config{Size: 100, Key: []byte("key"), Salt: []byte("salt"), Person: []byte("person")}
+	h, _ := NewXOF(c)
+	h.Write([]byte("hello"))
+	want := make([]byte, 100)
+	h.Read(want)
+
+	h.Reset()
+	h.Write([]byte("hello"))
+	got := make([]byte, 100)
+	n, err := h.Read(got)
+	if n != len(got) || err != nil {
+		t.Fatalf("error reading after reset: %v (n = %d)", err, n)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output after reset differs")
+	}
+
+	// Reset in the middle of absorbing.
+	h.Reset()
+	h.Write([]byte("garbage"))
+	h.Reset()
+	h.Write([]byte("hello"))
+	h.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("output after reset during absorbing differs")
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := &Config{Size: 100, Key: []byte("key")}
+	h, _ := NewXOF(c)
+	h.Write([]byte("header"))
+	c1, err := h.Clone()
+	if err != nil {
+		t.Fatalf("error cloning: %s", err)
+	}
+	c2, _ := h.Clone()
+	h.Write([]byte("a"))
+	c1.Write([]byte("a"))
+	c2.Write([]byte("b"))
+
+	want := make([]byte, 100)
+	h.Read(want[:10])
+
+	// Clone during reading.
+	c3, err := h.Clone()
+	if err != nil {
+		t.Fatalf("error cloning finalized: %s", err)
+	}
+	h.Read(want[10:])
+	got3 := make([]byte, 90)
+	c3.Read(got3)
+	if !bytes.Equal(got3, want[10:]) {
+		t.Errorf("finalized clone output differs")
+	}
+
+	got1 := make([]byte, 100)
+	c1.Read(got1)
+	if !bytes.Equal(got1, want) {
+		t.Errorf("clone with the same suffix differs")
+	}
+	got2 := make([]byte, 100)
+	c2.Read(got2)
+	if bytes.Equal(got2, want) {
+		t.Errorf("clone with different suffix produced the same output")
+	}
+	ref, _ := NewXOF(c)
+	ref.Write([]byte("headerb"))
+	ref.Read(want)
+	if !bytes.Equal(got2, want) {
+		t.Errorf("clone output differs from fresh XOF")
+	}
+}
+
+func TestSum(t *testing.T) {
+	for i, v := range goldenXOF {
+		in, _ := hex.DecodeString(v.in)
+		key, _ := hex.DecodeString(v.key)
+		out := make([]byte, len(v.out)/2)
+		if err := Sum(out, in, &Config{Key: key}); err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if hex.EncodeToString(out) != v.out {
+			t.Errorf("%d: expected %s, got %x", i, v.out, out)
+		}
+	}
+	if err := Sum(make([]byte, 32), nil, &Config{Size: 33}); err != ErrSizeMismatch {
+		t.Errorf("expected error for mismatched size")
+	}
+	if err := Sum(make([]byte, UnknownSize+1), nil, nil); err != ErrSize {
+		t.Errorf("expected error for too large output")
+	}
+	out := make([]byte, 64)
+	if err := Sum(out, []byte{1, 2, 3}, nil); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	h, _ := NewXOF(&Config{Size: 64})
+	h.Write([]byte{1, 2, 3})
+	want := make([]byte, 64)
+	h.Read(want)
+	if !bytes.Equal(out, want) {
+		t.Errorf("Sum with nil config differs from XOF output")
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 200})
+	h.Write([]byte{1, 2, 3})
+	want := make([]byte, 200)
+	h.ReadAt(want, 0)
+
+	d := h
+	pos := 0
+	b := make([]byte, 3)
+	for _, n := range []int{0, 1, 5, 32, 40, 64} {
+		m, err := d.Discard(n)
+		if m != n || err != nil {
+			t.Fatalf("Discard(%d) returned (%d, %v)", n, m, err)
+		}
+		pos += n
+		if _, err := io.ReadFull(h, b); err != nil {
+			t.Fatalf("error reading: %s", err)
+		}
+		if !bytes.Equal(b, want[pos:pos+3]) {
+			t.Errorf("wrong output after discarding to %d", pos)
+		}
+		pos += 3
+	}
+	m, err := d.Discard(1000)
+	if m != 200-pos || err != io.EOF {
+		t.Errorf("expected (%d, io.EOF), got (%d, %v)", 200-pos, m, err)
+	}
+	if n, err := h.Read(b); n != 0 || err != io.EOF {
+		t.Errorf("expected io.EOF after discarding everything")
+	}
+	if _, err := d.Discard(-1); err != ErrNegativeCount {
+		t.Errorf("expected error for negative count")
+	}
+}
+
+func TestReadByte(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 70})
+	want := make([]byte, 70)
+	h.ReadAt(want, 0)
+	b := make([]byte, 4)
+	for i := 0; i < len(want); {
+		if i%3 == 0 && i+len(b) <= len(want) {
+			// Mix Read and ReadByte.
+			h.Read(b)
+			if !bytes.Equal(b, want[i:i+len(b)]) {
+				t.Errorf("%d: wrong output from Read", i)
+			}
+			i += len(b)
+			continue
+		}
+		c, err := h.ReadByte()
+		if err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if c != want[i] {
+			t.Errorf("%d: expected %x, got %x", i, want[i], c)
+		}
+		i++
+	}
+	if _, err := h.ReadByte(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+type errWriter struct {
+	n int // bytes to accept before failing
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("write failed")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	for _, size := range []uint16{1, 33, 1024, 5000, UnknownSize} {
+		h, _ := NewXOF(&Config{Size: size})
+		want := make([]byte, size)
+		h.ReadAt(want, 0)
+		h.Read(make([]byte, 10))
+		var buf bytes.Buffer
+		n, err := h.WriteTo(&buf)
+		exp := int64(size) - 10
+		if exp < 0 {
+			exp = 0
+		}
+		if n != exp || err != nil {
+			t.Errorf("size %d: expected (%d, nil), got (%d, %v)", size, exp, n, err)
+		}
+		if exp > 0 && !bytes.Equal(buf.Bytes(), want[10:]) {
+			t.Errorf("size %d: output mismatch", size)
+		}
+	}
+	h, _ := NewXOF(&Config{Size: 5000})
+	n, err := h.WriteTo(&errWriter{n: 2000})
+	if n != 2000 || err == nil {
+		t.Errorf("expected (2000, error), got (%d, %v)", n, err)
+	}
+}
+
+func TestSizeRemaining(t *testing.T) {
+	for _, v := range []struct {
+		size uint16
+		want int
+	}{
+		{0, UnknownSize},
+		{1, 1},
+		{100, 100},
+		{UnknownSize, UnknownSize},
+	} {
+		h, _ := NewXOF(&Config{Size: v.size})
+		x := h
+		if x.Size() != v.want {
+			t.Errorf("size %d: expected Size() = %d, got %d", v.size, v.want, x.Size())
+		}
+		if x.Remaining() != v.want {
+			t.Errorf("size %d: expected Remaining() = %d, got %d", v.size, v.want, x.Remaining())
+		}
+		h.Read(make([]byte, 50))
+		left := v.want - 50
+		if left < 0 {
+			left = 0
+		}
+		if x.Remaining() != left {
+			t.Errorf("size %d: expected Remaining() = %d after reading, got %d", v.size, left, x.Remaining())
+		}
+	}
+}
+
+func BenchmarkBlock(b *testing.B) {
+	h, _ := NewXOF(nil)
+	h.finalize()
+	var buf [blake2s.Size]byte
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.block(buf[:], i%(UnknownSize/blake2s.Size))
+	}
+}
+
+func TestWriteAfterRead(t *testing.T) {
+	h, _ := NewXOF(nil)
+	h.Write([]byte{1})
+	h.Read(make([]byte, 1))
+	if _, err := h.Write([]byte{2}); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for i, v := range []struct {
+		c   Config
+		err error
+	}{
+		{Config{}, nil},
+		{Config{Key: make([]byte, 32), Salt: make([]byte, 8), Person: make([]byte, 8)}, nil},
+		{Config{Tree: &blake2s.Tree{Fanout: 2, MaxDepth: 2, NodeDepth: 1, InnerHashSize: 32}}, nil},
+		{Config{Key: make([]byte, 33)}, ErrKeySize},
+		{Config{Salt: make([]byte, 9)}, ErrSaltSize},
+		{Config{Person: make([]byte, 9)}, ErrPersonSize},
+		{Config{Tree: &blake2s.Tree{}}, ErrTree},
+		{Config{Tree: &blake2s.Tree{MaxDepth: 2, NodeDepth: 2}}, ErrTree},
+		{Config{Tree: &blake2s.Tree{MaxDepth: 1, InnerHashSize: 33}}, ErrTree},
+		{Config{Tree: &blake2s.Tree{MaxDepth: 1, NodeOffset: 1<<32 - 1}}, nil},
+		{Config{Tree: &blake2s.Tree{MaxDepth: 1, NodeOffset: 1 << 32}}, ErrNodeOffset},
+		{Config{Tree: &blake2s.Tree{MaxDepth: 1, NodeOffset: 1<<48 - 1}}, ErrNodeOffset},
+	} {
+		if err := v.c.Validate(); err != v.err {
+			t.Errorf("%d: expected %v, got %v", i, v.err, err)
+		}
+		if _, err := NewXOF(&v.c); err != v.err {
+			t.Errorf("%d: NewXOF: expected %v, got %v", i, v.err, err)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	key := []byte("secret key")
+	h, _ := NewXOF(&Config{Size: 100, Key: key})
+	h.Write([]byte("input"))
+	h.Read(make([]byte, 10))
+	h.Clear()
+	if !bytes.Equal(key, []byte("secret key")) {
+		t.Errorf("Clear modified caller's key")
+	}
+	var zero [blake2s.Size]byte
+	if h.h0 != zero || h.x != zero {
+		t.Errorf("digest or buffer not cleared")
+	}
+	for _, v := range h.rc.Key {
+		if v != 0 {
+			t.Fatalf("key not cleared")
+		}
+	}
+	if n, err := h.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("expected (0, io.EOF) after Clear, got (%d, %v)", n, err)
+	}
+}
+
+func TestClearClone(t *testing.T) {
+	want := make([]byte, 64)
+	Sum(want, []byte("input"), &Config{Size: 64, Key: []byte("secret key")})
+
+	h, _ := NewXOF(&Config{Size: 100, Key: []byte("secret key")})
+	c, _ := h.Clone()
+	h.Clear()
+	if err := c.SetSize(64); err != nil {
+		t.Fatalf("SetSize: %s", err)
+	}
+	c.Write([]byte("input"))
+	got := make([]byte, 64)
+	c.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("clone output changed after clearing original")
+	}
+
+	h, _ = NewXOF(&Config{Key: []byte("secret key")})
+	old := h.rc.Key
+	h.ResetWithKey([]byte("other"))
+	if !bytes.Equal(old, make([]byte, len(old))) {
+		t.Errorf("ResetWithKey didn't wipe old key")
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	in := "The quick brown fox jumps over the lazy dog, twice: the quick brown fox jumps over the lazy dog."
+	h, _ := NewXOF(&Config{Size: 64})
+	h.Write([]byte(in))
+	want := make([]byte, 64)
+	h.Read(want)
+
+	h, _ = NewXOF(&Config{Size: 64})
+	n, err := h.WriteString(in[:10])
+	if n != 10 || err != nil {
+		t.Errorf("expected (10, nil), got (%d, %v)", n, err)
+	}
+	h.WriteString(in[10:])
+	got := make([]byte, 64)
+	h.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("WriteString output differs from Write")
+	}
+	if _, err := h.WriteString("x"); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	if n := testing.AllocsPerRun(10, func() { h.Reset(); h.WriteString(in) }); n != 0 {
+		t.Errorf("WriteString allocates: %v allocs", n)
+	}
+}
+
+func TestReadBoundary(t *testing.T) {
+	read := func(h *XOF, n int) (int, error) { return h.Read(make([]byte, n)) }
+
+	// Exactly at the boundary.
+	h, _ := NewXOF(&Config{Size: 40})
+	read(h, 10)
+	if n, err := read(h, 30); n != 30 || err != nil {
+		t.Errorf("exact: expected (30, nil), got (%d, %v)", n, err)
+	}
+	if n, err := read(h, 1); n != 0 || err != io.EOF {
+		t.Errorf("exact: expected (0, io.EOF), got (%d, %v)", n, err)
+	}
+
+	// Crossing the boundary.
+	h, _ = NewXOF(&Config{Size: 40})
+	read(h, 10)
+	if n, err := read(h, 31); n != 30 || err != io.EOF {
+		t.Errorf("cross: expected (30, io.EOF), got (%d, %v)", n, err)
+	}
+
+	// Starting past the boundary.
+	if n, err := read(h, 5); n != 0 || err != io.EOF {
+		t.Errorf("past: expected (0, io.EOF), got (%d, %v)", n, err)
+	}
+	if n, err := read(h, 0); n != 0 || err != nil {
+		t.Errorf("past, empty: expected (0, nil), got (%d, %v)", n, err)
+	}
+}
+
+func TestReadEOFContract(t *testing.T) {
+	for _, size := range []int{1, 31, 32, 33, 64, 65, 100} {
+		blocks := (size + blake2s.Size - 1) / blake2s.Size
+		for _, ln := range []int{size - 1, size, size + 1, size + 32, 2 * size} {
+			if ln < 1 {
+				continue
+			}
+			h, _ := NewXOF(&Config{Size: uint16(size)})
+			n, err := h.Read(make([]byte, ln))
+			switch {
+			case ln <= size && (n != ln || err != nil):
+				t.Errorf("size %d, len %d: expected (%d, nil), got (%d, %v)", size, ln, ln, n, err)
+			case ln > size && (n != size || err != io.EOF):
+				t.Errorf("size %d, len %d: expected (%d, io.EOF), got (%d, %v)", size, ln, size, n, err)
+			}
+			if ln >= size {
+				if n, err := h.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+					t.Errorf("size %d, len %d: expected (0, io.EOF) on next read, got (%d, %v)", size, ln, n, err)
+				}
+			}
+			if h.next > blocks {
+				t.Errorf("size %d, len %d: generated %d blocks, expected at most %d", size, ln, h.next, blocks)
+			}
+		}
+	}
+}
+
+func TestUnknownSizeLimit(t *testing.T) {
+	h, _ := NewXOF(nil)
+	b := make([]byte, UnknownSize+10)
+	n, err := h.Read(b)
+	if n != UnknownSize || err != io.EOF {
+		t.Errorf("expected (%d, io.EOF), got (%d, %v)", UnknownSize, n, err)
+	}
+	if h.Remaining() != 0 {
+		t.Errorf("expected Remaining() = 0, got %d", h.Remaining())
+	}
+	if n, err := h.Read(b[:1]); n != 0 || err != io.EOF {
+		t.Errorf("expected (0, io.EOF) past UnknownSize, got (%d, %v)", n, err)
+	}
+}
+
+func TestConfigNotModified(t *testing.T) {
+	c := &Config{
+		Size: 100,
+		Tree: &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 5},
+	}
+	var outs [2][]byte
+	for i := range outs {
+		h, err := NewXOF(c)
+		if err != nil {
+			t.Fatalf("%d: error creating: %s", i, err)
+		}
+		h.Write([]byte{1, 2, 3})
+		outs[i] = make([]byte, 100)
+		h.Read(outs[i])
+	}
+	if c.Tree.NodeOffset != 5 {
+		t.Errorf("NewXOF modified config tree: NodeOffset = %d", c.Tree.NodeOffset)
+	}
+	if !bytes.Equal(outs[0], outs[1]) {
+		t.Errorf("XOFs created from the same config produced different outputs")
+	}
+}
+
+func TestConfigSlicesCopied(t *testing.T) {
+	newConfig := func() *Config {
+		return &Config{
+			Size:   100,
+			Key:    []byte("key"),
+			Salt:   []byte("salt"),
+			Person: []byte("person"),
+		}
+	}
+	h, _ := NewXOF(newConfig())
+	h.Write([]byte("input"))
+	want := make([]byte, 100)
+	h.Read(want)
+
+	c := newConfig()
+	h, _ = NewXOF(c)
+	for _, b := range [][]byte{c.Key, c.Salt, c.Person} {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+	h.Write([]byte("input"))
+	got := make([]byte, 100)
+	h.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("changing config slices after NewXOF changed output")
+	}
+	h.Reset()
+	h.Write([]byte("input"))
+	h.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("changing config slices after NewXOF changed output after Reset")
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 1000})
+	want := make([]byte, 1000)
+	h.ReadAt(want, 0)
+
+	got := make([]byte, 1000)
+	n, err := h.ReadContext(context.Background(), got[:5])
+	if n != 5 || err != nil {
+		t.Fatalf("expected (5, nil), got (%d, %v)", n, err)
+	}
+	n, err = h.ReadContext(context.Background(), got[5:])
+	if n != 995 || err != nil {
+		t.Fatalf("expected (995, nil), got (%d, %v)", n, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ReadContext output differs from Read")
+	}
+	if n, err := h.ReadContext(context.Background(), got[:1]); n != 0 || err != io.EOF {
+		t.Errorf("expected (0, io.EOF), got (%d, %v)", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h, _ = NewXOF(&Config{Size: 1000})
+	if n, err := h.ReadContext(ctx, got); n != 0 || err != context.Canceled {
+		t.Errorf("expected (0, context.Canceled), got (%d, %v)", n, err)
+	}
+	if h.Remaining() != 1000 {
+		t.Errorf("cancelled ReadContext consumed output")
+	}
+}
+
+func TestConcurrentReader(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 1000})
+	want := make([]byte, 1000)
+	h.ReadAt(want, 0)
+
+	r := NewConcurrentReader(h)
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := make([]byte, 10)
+			for j := 0; j < 10; j++ {
+				if _, err := io.ReadFull(r, b); err != nil {
+					t.Errorf("error reading: %s", err)
+					return
+				}
+				off := bytes.Index(want, b)
+				mu.Lock()
+				if off < 0 || off%10 != 0 || seen[off] {
+					t.Errorf("unexpected chunk at offset %d", off)
+				}
+				seen[off] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 100 {
+		t.Errorf("expected 100 distinct chunks, got %d", len(seen))
+	}
+}
+
+func TestNewXOFSize(t *testing.T) {
+	for _, size := range []int{0, 1, 100, UnknownSize} {
+		h, err := NewXOFSize(size)
+		if err != nil {
+			t.Fatalf("size %d: error: %s", size, err)
+		}
+		h2, _ := NewXOF(&Config{Size: uint16(size)})
+		if h.Size() != h2.Size() {
+			t.Errorf("size %d: expected size %d, got %d", size, h2.Size(), h.Size())
+		}
+		out1, out2 := make([]byte, 64), make([]byte, 64)
+		h.Read(out1)
+		h2.Read(out2)
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("size %d: output differs from NewXOF", size)
+		}
+	}
+	for _, size := range []int{-1, UnknownSize + 1} {
+		if _, err := NewXOFSize(size); err != ErrSize {
+			t.Errorf("size %d: expected ErrSize, got %v", size, err)
+		}
+	}
+}
+
+func TestAbsorbSqueeze(t *testing.T) {
+	h1, _ := NewXOF(&Config{Size: 64})
+	h2, _ := NewXOF(&Config{Size: 64})
+	h1.Write([]byte("input"))
+	h2.Absorb([]byte("input"))
+	out1, out2 := make([]byte, 64), make([]byte, 64)
+	h1.Read(out1)
+	if n, err := h2.Squeeze(out2); n != 64 || err != nil {
+		t.Errorf("expected (64, nil), got (%d, %v)", n, err)
+	}
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("Absorb/Squeeze output differs from Write/Read")
+	}
+	if _, err := h2.Absorb([]byte{1}); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+}
+
+func TestFill(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	want := make([]byte, 100)
+	h.ReadAt(want, 0)
+	buf := make([]byte, 48)
+	if err := h.Fill(buf); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !bytes.Equal(buf, want[:48]) {
+		t.Errorf("wrong output")
+	}
+	if err := h.Fill(buf); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if err := h.Fill(buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if err := h.Fill(buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF when exhausted, got %v", err)
+	}
+	if err := h.Fill(nil); err != nil {
+		t.Errorf("expected nil error for empty buffer, got %v", err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	want := make([]byte, 100)
+	h.ReadAt(want, 0)
+	h.Read(make([]byte, 20))
+	for _, n := range []int{0, 1, 12, 13, 50} {
+		p, err := h.Peek(n)
+		if len(p) != n || err != nil {
+			t.Fatalf("Peek(%d): got (%d bytes, %v)", n, len(p), err)
+		}
+		if !bytes.Equal(p, want[20:20+n]) {
+			t.Errorf("Peek(%d): wrong output", n)
+		}
+	}
+	p, _ := h.Peek(5)
+	p[0] ^= 1 // must not affect output
+	b := make([]byte, 50)
+	h.Read(b)
+	if !bytes.Equal(b, want[20:70]) {
+		t.Errorf("Read after Peek returned wrong output")
+	}
+	p, err := h.Peek(40)
+	if len(p) != 30 || err != io.EOF {
+		t.Errorf("expected (30 bytes, io.EOF), got (%d bytes, %v)", len(p), err)
+	}
+	if _, err := h.Peek(-1); err != ErrNegativeCount {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
+
+var errBackend = errors.New("backend failure")
+
+// testBackend wraps blake2s.New, counting created hashes.
+type testBackend struct {
+	calls  int
+	failAt int // if not zero, fail on this call
+}
+
+func (b *testBackend) New(c *blake2s.Config) (hash.Hash, error) {
+	b.calls++
+	if b.calls == b.failAt {
+		return nil, errBackend
+	}
+	return blake2s.New(c)
+}
+
+func TestBackend(t *testing.T) {
+	for i, v := range goldenXOF {
+		in, _ := hex.DecodeString(v.in)
+		key, _ := hex.DecodeString(v.key)
+		size := len(v.out) / 2
+		var b testBackend
+		h, err := NewXOF(&Config{Size: uint16(size), Key: key, Backend: b.New})
+		if err != nil {
+			t.Fatalf("%d: error creating: %s", i, err)
+		}
+		h.Write(in)
+		out := make([]byte, size)
+		h.Read(out)
+		if hex.EncodeToString(out) != v.out {
+			t.Errorf("%d: expected %s, got %x", i, v.out, out)
+		}
+		if blocks := (size + blake2s.Size - 1) / blake2s.Size; b.calls != 1+blocks {
+			t.Errorf("%d: expected %d backend calls, got %d", i, 1+blocks, b.calls)
+		}
+	}
+	b := testBackend{failAt: 1}
+	if _, err := NewXOF(&Config{Backend: b.New}); err != errBackend {
+		t.Errorf("expected backend error, got %v", err)
+	}
+}
+
+func TestWriteN(t *testing.T) {
+	h, _ := NewXOF(nil)
+	want := make([]byte, 5000)
+	h.ReadAt(want, 0)
+	var buf bytes.Buffer
+	for _, n := range []int64{0, 1, 31, 1000, 3968} {
+		m, err := h.WriteN(&buf, n)
+		if m != n || err != nil {
+			t.Errorf("WriteN(%d): got (%d, %v)", n, m, err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output mismatch")
+	}
+
+	h, _ = NewXOF(&Config{Size: 100})
+	buf.Reset()
+	h.Read(make([]byte, 10))
+	if m, err := h.WriteN(&buf, 1000); m != 90 || err != io.EOF {
+		t.Errorf("expected (90, io.EOF), got (%d, %v)", m, err)
+	}
+	if _, err := h.WriteN(&buf, -1); err != ErrNegativeCount {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	want := make([]byte, 100)
+	h.ReadAt(want, 0)
+	for _, v := range []struct {
+		n    int64
+		want []byte
+	}{
+		{40, want[:40]},
+		{0, nil},
+		{1000, want[40:]},
+	} {
+		b, err := io.ReadAll(h.Limit(v.n))
+		if err != nil {
+			t.Fatalf("Limit(%d): error: %s", v.n, err)
+		}
+		if !bytes.Equal(b, v.want) {
+			t.Errorf("Limit(%d): got %d bytes, expected %d", v.n, len(b), len(v.want))
+		}
+	}
+}
+
+func TestSaltPersonTooLong(t *testing.T) {
+	for _, v := range []struct {
+		c   *Config
+		err error
+		msg string
+	}{
+		{&Config{Salt: []byte("123456789")}, ErrSaltSize, "blake2xs: salt too long (max 8 bytes)"},
+		{&Config{Person: []byte("123456789")}, ErrPersonSize, "blake2xs: personalization too long (max 8 bytes)"},
+	} {
+		_, err := NewXOF(v.c)
+		if err != v.err {
+			t.Errorf("expected %v, got %v", v.err, err)
+			continue
+		}
+		if err.Error() != v.msg {
+			t.Errorf("expected message %q, got %q", v.msg, err.Error())
+		}
+	}
+	// Shorter values are padded with zeros.
+	h1, _ := NewXOF(&Config{Size: 32, Salt: []byte("salt")})
+	h2, _ := NewXOF(&Config{Size: 32, Salt: []byte("salt\x00\x00\x00\x00")})
+	out1, out2 := make([]byte, 32), make([]byte, 32)
+	h1.Read(out1)
+	h2.Read(out2)
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("short salt is not padded with zeros")
+	}
+}
+
+func TestSectionReader(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 1000, Key: []byte("key")})
+	want := make([]byte, 1000)
+	h.ReadAt(want, 0)
+	for _, v := range []struct{ off, n int64 }{{0, 1000}, {1, 31}, {33, 500}, {999, 1}, {900, 200}} {
+		end := v.off + v.n
+		if end > 1000 {
+			end = 1000
+		}
+		sr := io.NewSectionReader(h, v.off, v.n)
+		b, err := io.ReadAll(sr)
+		if err != nil {
+			t.Fatalf("off %d, n %d: error: %s", v.off, v.n, err)
+		}
+		if !bytes.Equal(b, want[v.off:end]) {
+			t.Errorf("off %d, n %d: SectionReader output mismatch", v.off, v.n)
+		}
+
+		// The same window through Seek and Read.
+		if _, err := h.Seek(v.off, io.SeekStart); err != nil {
+			t.Fatalf("off %d: error seeking: %s", v.off, err)
+		}
+		b, _ = io.ReadAll(io.LimitReader(h, v.n))
+		if !bytes.Equal(b, want[v.off:end]) {
+			t.Errorf("off %d, n %d: Seek/Read output mismatch", v.off, v.n)
+		}
+		if pos, _ := h.Seek(0, io.SeekCurrent); pos != end {
+			t.Errorf("off %d, n %d: expected position %d, got %d", v.off, v.n, end, pos)
+		}
+	}
+}
+
+func BenchmarkReadSteady(b *testing.B) {
+	h, _ := NewXOF(nil)
+	buf := make([]byte, 1024)
+	h.Read(buf)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if h.Remaining() < len(buf) {
+			h.Seek(0, io.SeekStart)
+		}
+		h.Read(buf)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	h, _ := NewXOF(nil)
+	b.SetBytes(UnknownSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Seek(0, io.SeekStart)
+		h.WriteTo(io.Discard)
+	}
+}
+
+func TestRootDigest(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	h.Write([]byte("abc"))
+	d, err := h.RootDigest()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	rh, _ := blake2s.New(&blake2s.Config{
+		Size: blake2s.Size,
+		Tree: &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 100 << 32},
+	})
+	rh.Write([]byte("abc"))
+	if want := rh.Sum(nil); !bytes.Equal(d, want) {
+		t.Errorf("expected %x, got %x", want, d)
+	}
+	d[0] ^= 1
+	if d2, _ := h.RootDigest(); bytes.Equal(d, d2) {
+		t.Errorf("modifying returned digest changed internal state")
+	}
+	if _, err := h.Write([]byte("x")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+}
+
+func TestSetSize(t *testing.T) {
+	tree := &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 5}
+	for _, size := range []int{0, 1, 100, UnknownSize} {
+		h, _ := NewXOF(&Config{Size: 32, Tree: tree})
+		if err := h.SetSize(size); err != nil {
+			t.Fatalf("%d: error: %s", size, err)
+		}
+		want := size
+		if want == 0 {
+			want = UnknownSize
+		}
+		h2, _ := NewXOF(&Config{Size: uint16(size), Tree: tree})
+		if h.Size() != want || h.Remaining() != want {
+			t.Errorf("%d: expected size %d, got %d", size, want, h.Size())
+		}
+		h.Write([]byte("abc"))
+		h2.Write([]byte("abc"))
+		out1, out2 := make([]byte, want), make([]byte, want)
+		h.Read(out1)
+		h2.Read(out2)
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("%d: output differs from NewXOF with the same size", size)
+		}
+	}
+
+	h, _ := NewXOF(nil)
+	if err := h.SetSize(UnknownSize + 1); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+	h.Write([]byte("abc"))
+	if err := h.SetSize(10); err != ErrSetSize {
+		t.Errorf("after Write: expected ErrSetSize, got %v", err)
+	}
+	h.Reset()
+	h.ReadByte()
+	if err := h.SetSize(10); err != ErrSetSize {
+		t.Errorf("after Read: expected ErrSetSize, got %v", err)
+	}
+	h.Reset()
+	if err := h.SetSize(10); err != nil {
+		t.Errorf("after Reset: error: %s", err)
+	}
+}
+
+func TestReadFull(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	n, err := h.ReadFull(make([]byte, 60))
+	if n != 60 || err != nil {
+		t.Errorf("expected 60, nil; got %d, %v", n, err)
+	}
+	n, err = h.ReadFull(make([]byte, 60))
+	if n != 40 || err != io.ErrUnexpectedEOF {
+		t.Errorf("expected 40, io.ErrUnexpectedEOF; got %d, %v", n, err)
+	}
+	n, err = h.ReadFull(make([]byte, 60))
+	if n != 0 || err != io.EOF {
+		t.Errorf("expected 0, io.EOF; got %d, %v", n, err)
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	input := make([]byte, 3*bufferSize+5)
+	for i := range input {
+		input[i] = byte(i)
+	}
+	h, _ := NewXOF(&Config{Size: 64})
+	n, err := h.ReadFrom(bytes.NewReader(input))
+	if n != int64(len(input)) || err != nil {
+		t.Fatalf("expected %d, nil; got %d, %v", len(input), n, err)
+	}
+	got := make([]byte, 64)
+	h.Read(got)
+	want := make([]byte, 64)
+	Sum(want, input, &Config{Size: 64})
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+	if _, err := h.ReadFrom(bytes.NewReader(input)); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+}
+
+func TestXOFSum(t *testing.T) {
+	for _, size := range []int{1, 32, 100, UnknownSize} {
+		h, _ := NewXOFSize(size)
+		h.Write([]byte("abc"))
+		want := make([]byte, size)
+		Sum(want, []byte("abc"), nil)
+		prefix := []byte("prefix")
+		got := h.Sum(prefix)
+		if !bytes.Equal(got[:len(prefix)], prefix) || !bytes.Equal(got[len(prefix):], want) {
+			t.Errorf("%d: wrong sum", size)
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d: repeated sum differs", size)
+		}
+		out := make([]byte, size)
+		if _, err := io.ReadFull(h, out); err != nil || !bytes.Equal(out, want) {
+			t.Errorf("%d: Read after Sum returned wrong output (err = %v)", size, err)
+		}
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	h.Write([]byte("abc"))
+	if err := h.Finalize(); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if _, err := h.Write([]byte("x")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), &Config{Size: 100})
+	got := make([]byte, 100)
+	if _, err := io.ReadFull(h, got); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("wrong output after Finalize (err = %v)", err)
+	}
+
+	b := testBackend{failAt: 2}
+	h, _ = NewXOF(&Config{Backend: b.New})
+	if err := h.Finalize(); err != errBackend {
+		t.Errorf("expected errBackend, got %v", err)
+	}
+}
+
+func TestReadBlockAt(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	h.Write([]byte("abc"))
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), &Config{Size: 100})
+	for i := 0; i < 4; i++ {
+		n := BlockSize
+		if i == 3 {
+			n = 100 - 3*BlockSize
+		}
+		dst := make([]byte, n)
+		if err := h.ReadBlockAt(dst, uint64(i)); err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if !bytes.Equal(dst, want[i*BlockSize:i*BlockSize+n]) {
+			t.Errorf("%d: expected %x, got %x", i, want[i*BlockSize:i*BlockSize+n], dst)
+		}
+	}
+	if err := h.ReadBlockAt(make([]byte, BlockSize), 4); err != ErrBlockIndex {
+		t.Errorf("expected ErrBlockIndex, got %v", err)
+	}
+	if err := h.ReadBlockAt(make([]byte, BlockSize), 3); err != ErrBlockLength {
+		t.Errorf("expected ErrBlockLength, got %v", err)
+	}
+	if err := h.ReadBlockAt(make([]byte, 4), 0); err != ErrBlockLength {
+		t.Errorf("expected ErrBlockLength, got %v", err)
+	}
+	if h.Remaining() != 100 {
+		t.Errorf("ReadBlockAt changed read position")
+	}
+}
+
+func TestResetWithKey(t *testing.T) {
+	salt := []byte("salt")
+	h, _ := NewXOF(&Config{Size: 100, Key: []byte("first key"), Salt: salt})
+	h.Write([]byte("abc"))
+	h.ReadByte()
+	for _, key := range [][]byte{[]byte("second key"), nil, make([]byte, 32)} {
+		if err := h.ResetWithKey(key); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		h.Write([]byte("abc"))
+		got := make([]byte, 100)
+		h.Read(got)
+		want := make([]byte, 100)
+		Sum(want, []byte("abc"), &Config{Key: key, Salt: salt})
+		if !bytes.Equal(got, want) {
+			t.Errorf("key %q: expected %x, got %x", key, want, got)
+		}
+	}
+	if err := h.ResetWithKey(make([]byte, 33)); err != ErrKeySize {
+		t.Errorf("expected ErrKeySize, got %v", err)
+	}
+}
+
+func TestHexBase64String(t *testing.T) {
+	want := make([]byte, 100)
+	Sum(want, nil, &Config{Size: 100})
+	h, _ := NewXOF(&Config{Size: 100})
+	s, err := h.HexString(40)
+	if err != nil || s != hex.EncodeToString(want[:40]) {
+		t.Errorf("HexString: expected %x, got %s (err = %v)", want[:40], s, err)
+	}
+	s, err = h.Base64String(50)
+	if err != nil || s != base64.StdEncoding.EncodeToString(want[40:90]) {
+		t.Errorf("Base64String: wrong output %s (err = %v)", s, err)
+	}
+	if _, err := h.HexString(11); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := h.Base64String(-1); err != ErrNegativeCount {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	want := make([]byte, 1000)
+	Sum(want, nil, &Config{Size: 1000})
+	h, _ := NewXOFSize(1000)
+	r, stop := h.Pipe()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong output from pipe")
+	}
+	stop()
+	stop()
+
+	// Stop before reading everything.
+	h, _ = NewXOF(nil)
+	r, stop = h.Pipe()
+	b := make([]byte, 100)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	stop()
+	if _, err := r.Read(b); err != io.ErrClosedPipe {
+		t.Errorf("expected io.ErrClosedPipe after stop, got %v", err)
+	}
+	if h.Remaining() == 0 {
+		t.Errorf("goroutine didn't stop early")
+	}
+}
+
+func TestChild(t *testing.T) {
+	h, _ := NewXOF(&Config{Key: []byte("key")})
+	h.Write([]byte("abc"))
+	c0, err := h.Child(0, 64)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	c1, _ := h.Child(1, 64)
+	out0, out1 := make([]byte, 64), make([]byte, 64)
+	c0.Read(out0)
+	c1.Read(out1)
+	if bytes.Equal(out0, out1) {
+		t.Errorf("children with different labels have the same output")
+	}
+	if _, err := c0.Write([]byte("x")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead writing to child, got %v", err)
+	}
+
+	h0, _ := h.RootDigest()
+	want := make([]byte, 64)
+	Sum(want, nil, &Config{Key: h0, Person: []byte{1, 0, 0, 0, 0, 0, 0, 0}})
+	if !bytes.Equal(out1, want) {
+		t.Errorf("expected %x, got %x", want, out1)
+	}
+	if _, err := h.Child(0, UnknownSize+1); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	for _, size := range []int{1, 32, 100} {
+		h, _ := NewXOFSize(size)
+		h.Write([]byte("abc"))
+		h.ReadByte()
+		b, err := h.Bytes()
+		if err != nil {
+			t.Fatalf("%d: error: %s", size, err)
+		}
+		want := make([]byte, size)
+		Sum(want, []byte("abc"), nil)
+		if !bytes.Equal(b, want) {
+			t.Errorf("%d: expected %x, got %x", size, want, b)
+		}
+		if h.Remaining() != size-1 {
+			t.Errorf("%d: Bytes changed read position", size)
+		}
+	}
+	h, _ := NewXOF(nil)
+	if _, err := h.Bytes(); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+}
+
+func TestRewind(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	h.Write([]byte("abc"))
+	h.Read(make([]byte, 50))
+	h.Rewind()
+	if _, err := h.Write([]byte("def")); err != nil {
+		t.Fatalf("error writing after Rewind: %s", err)
+	}
+	got := make([]byte, 100)
+	if _, err := io.ReadFull(h, got); err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	want := make([]byte, 100)
+	Sum(want, []byte("abcdef"), nil)
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+}
+
+func TestSumBatch(t *testing.T) {
+	inputs := [][]byte{nil, []byte("a"), []byte("abc"), make([]byte, 1000), []byte("b")}
+	for _, c := range []*Config{nil, {Key: []byte("key")}} {
+		out := make([][]byte, len(inputs))
+		for i := range out {
+			out[i] = make([]byte, 1+i*20)
+		}
+		if err := SumBatch(out, inputs, c); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		for i := range out {
+			want := make([]byte, len(out[i]))
+			Sum(want, inputs[i], c)
+			if !bytes.Equal(out[i], want) {
+				t.Errorf("%d: expected %x, got %x", i, want, out[i])
+			}
+		}
+	}
+	if err := SumBatch(make([][]byte, 1), nil, nil); err != ErrBatchLength {
+		t.Errorf("expected ErrBatchLength, got %v", err)
+	}
+	out := [][]byte{make([]byte, 32), make([]byte, 16)}
+	if err := SumBatch(out, [][]byte{nil, nil}, &Config{Size: 32}); err != ErrSizeMismatch {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}
+
+func BenchmarkSumBatch(b *testing.B) {
+	inputs := make([][]byte, 64)
+	out := make([][]byte, len(inputs))
+	for i := range inputs {
+		inputs[i] = make([]byte, 64)
+		out[i] = make([]byte, 32)
+	}
+	c := &Config{Key: []byte("key")}
+	b.Run("Batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SumBatch(out, inputs, c)
+		}
+	})
+	b.Run("Loop", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := range inputs {
+				Sum(out[j], inputs[j], c)
+			}
+		}
+	})
+}
+
+func TestTooLargeSize(t *testing.T) {
+	for _, size := range []int{UnknownSize + 1, 1<<16 + 32, 1 << 20} {
+		h, _ := NewXOF(nil)
+		b, _ := NewBuilder(nil)
+		out := make([]byte, size)
+		for name, f := range map[string]func() error{
+			"NewXOFSize": func() error { _, err := NewXOFSize(size); return err },
+			"New":        func() error { _, err := New(size, nil); return err },
+			"NewMAC":     func() error { _, err := NewMAC(nil, size); return err },
+			"SetSize":    func() error { return h.SetSize(size) },
+			"Child":      func() error { _, err := h.Child(0, size); return err },
+			"Builder":    func() error { _, err := b.NewWithSize(size); return err },
+			"TreeLeaf":   func() error { _, err := NewTreeLeaf(64, size, 0, true); return err },
+			"TreeRoot":   func() error { _, err := NewTreeRoot(64, size); return err },
+			"Sum":        func() error { return Sum(out, nil, nil) },
+			"SumBatch":   func() error { return SumBatch([][]byte{out}, [][]byte{nil}, nil) },
+			"TreeSum":    func() error { return TreeSum(out, nil, 64) },
+			"DeriveKey":  func() error { return DeriveKey(out, nil, nil, nil) },
+			"Expand":     func() error { return Expand(out, nil, nil) },
+		} {
+			if err := f(); err != ErrSize {
+				t.Errorf("%s(%d): expected ErrSize, got %v", name, size, err)
+			}
+		}
+	}
+}
+
+func TestInvalidPositionArguments(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 100})
+	h.Read(make([]byte, 10))
+	for i, f := range []func() error{
+		func() error { _, err := h.Discard(-1); return err },
+		func() error { _, err := h.Seek(-11, io.SeekCurrent); return err },
+		func() error { _, err := h.Seek(-101, io.SeekEnd); return err },
+		func() error { _, err := h.Seek(-1, io.SeekStart); return err },
+		func() error { _, err := h.Seek(math.MaxInt64, io.SeekCurrent); return err },
+		func() error { _, err := h.Seek(math.MaxInt64, io.SeekEnd); return err },
+		func() error { _, err := h.Seek(math.MinInt64, io.SeekCurrent); return err },
+		func() error { _, err := h.ReadAt(make([]byte, 1), -1); return err },
+		func() error { _, err := h.Peek(-1); return err },
+		func() error { _, err := h.WriteN(io.Discard, -1); return err },
+	} {
+		if err := f(); err == nil {
+			t.Errorf("%d: expected error", i)
+		}
+		if h.Remaining() != 90 {
+			t.Fatalf("%d: position changed after error", i)
+		}
+	}
+	if _, err := h.Seek(math.MaxInt64, io.SeekCurrent); err != ErrSeekOverflow {
+		t.Errorf("expected ErrSeekOverflow, got %v", err)
+	}
+	if _, err := h.Seek(-11, io.SeekCurrent); err != ErrNegativePosition {
+		t.Errorf("expected ErrNegativePosition, got %v", err)
+	}
+}
+
+func TestEqualReader(t *testing.T) {
+	ref := make([]byte, 5000)
+	Sum(ref, []byte("abc"), &Config{Size: 5000})
+	newXOF := func() *XOF {
+		h, _ := NewXOFSize(5000)
+		h.Write([]byte("abc"))
+		return h
+	}
+	if eq, err := newXOF().EqualReader(bytes.NewReader(ref), len(ref)); !eq || err != nil {
+		t.Errorf("expected equal, got %v, %v", eq, err)
+	}
+	bad := append([]byte(nil), ref...)
+	bad[3000] ^= 1
+	if eq, err := newXOF().EqualReader(bytes.NewReader(bad), len(bad)); eq || err != nil {
+		t.Errorf("expected not equal, got %v, %v", eq, err)
+	}
+	if _, err := newXOF().EqualReader(bytes.NewReader(ref[:100]), 200); err != io.ErrUnexpectedEOF {
+		t.Errorf("short reader: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := newXOF().EqualReader(bytes.NewReader(ref), 6000); err != io.ErrUnexpectedEOF {
+		t.Errorf("short output: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestReadChunksShortLastBlock(t *testing.T) {
+	// Golden vector i has output size i+1.
+	for _, size := range []int{33, 63, 65, 95, 97} {
+		v := goldenXOF[size-1]
+		in, _ := hex.DecodeString(v.in)
+		want, _ := hex.DecodeString(v.out)
+		for _, chunks := range [][]int{{1}, {7}, {31}, {32}, {33}, {size - 1, 1}, {size - 2, 1, 1}, {1, 31, 7}} {
+			h, _ := NewXOF(&Config{Size: uint16(size)})
+			h.Write(in)
+			var got []byte
+			for i := 0; len(got) < size; i++ {
+				b := make([]byte, chunks[i%len(chunks)])
+				n, err := h.Read(b)
+				got = append(got, b[:n]...)
+				if err != nil && !(err == io.EOF && len(got) == size) {
+					t.Fatalf("size %d, chunks %v: error reading: %s", size, chunks, err)
+				}
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("size %d, chunks %v: expected %x, got %x", size, chunks, want, got)
+			}
+			if n, err := h.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+				t.Errorf("size %d, chunks %v: expected 0, io.EOF after output; got %d, %v", size, chunks, n, err)
+			}
+		}
+	}
+}
+
+func TestWriteFramed(t *testing.T) {
+	sum := func(fields ...string) []byte {
+		h, _ := NewXOFSize(32)
+		for _, f := range fields {
+			if n, err := h.WriteFramed([]byte(f)); n != len(f) || err != nil {
+				t.Fatalf("error writing: %v (n = %d)", err, n)
+			}
+		}
+		out := make([]byte, 32)
+		h.Read(out)
+		return out
+	}
+	if bytes.Equal(sum("ab", "c"), sum("a", "bc")) {
+		t.Errorf("framed writes of different fields collide")
+	}
+	want := make([]byte, 32)
+	Sum(want, []byte("\x00\x00\x00\x00\x00\x00\x00\x02ab\x00\x00\x00\x00\x00\x00\x00\x00"), nil)
+	if got := sum("ab", ""); !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+	h, _ := NewXOF(nil)
+	h.ReadByte()
+	if _, err := h.WriteFramed([]byte("a")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+}
+
+// failWriteHash is a hash, which accepts n bytes and then fails.
+type failWriteHash struct {
+	hash.Hash
+	n int
+}
+
+func (h *failWriteHash) Write(p []byte) (int, error) {
+	if len(p) > h.n {
+		n := h.n
+		h.Hash.Write(p[:n])
+		h.n = 0
+		return n, errBackend
+	}
+	h.n -= len(p)
+	return h.Hash.Write(p)
+}
+
+func TestWriteCount(t *testing.T) {
+	for _, size := range []int{0, 1, blake2s.BlockSize - 1, blake2s.BlockSize, blake2s.BlockSize + 1, 100 * blake2s.BlockSize} {
+		h, _ := NewXOF(nil)
+		n, err := h.Write(make([]byte, size))
+		if n != size || err != nil {
+			t.Errorf("Write %d: expected %d, nil; got %d, %v", size, size, n, err)
+		}
+		n, err = h.WriteString(string(make([]byte, size)))
+		if n != size || err != nil {
+			t.Errorf("WriteString %d: expected %d, nil; got %d, %v", size, size, n, err)
+		}
+	}
+
+	backend := func(c *blake2s.Config) (hash.Hash, error) {
+		h, err := blake2s.New(c)
+		return &failWriteHash{Hash: h, n: 100}, err
+	}
+	h, _ := NewXOF(&Config{Backend: backend})
+	if n, err := h.Write(make([]byte, 60)); n != 60 || err != nil {
+		t.Errorf("expected 60, nil; got %d, %v", n, err)
+	}
+	if n, err := h.Write(make([]byte, 60)); n != 40 || err != errBackend {
+		t.Errorf("expected 40, errBackend; got %d, %v", n, err)
+	}
+	h, _ = NewXOF(&Config{Backend: backend})
+	if n, err := h.WriteString(string(make([]byte, 2000))); n != 100 || err != errBackend {
+		t.Errorf("WriteString: expected 100, errBackend; got %d, %v", n, err)
+	}
+	h, _ = NewXOF(&Config{Backend: backend})
+	if n, err := h.ReadFrom(bytes.NewReader(make([]byte, 2000))); n != 100 || err != errBackend {
+		t.Errorf("ReadFrom: expected 100, errBackend; got %d, %v", n, err)
+	}
+}
+
+func TestReuseConfigSizes(t *testing.T) {
+	for _, tree := range []*blake2s.Tree{nil, {Fanout: 1, MaxDepth: 1, NodeOffset: 3}} {
+		c := &Config{Key: []byte("key"), Salt: []byte("salt"), Tree: tree}
+		for _, size := range []uint16{32, 64, 1, UnknownSize, 32} {
+			c.Size = size
+			h, err := NewXOF(c)
+			if err != nil {
+				t.Fatalf("%d: error: %s", size, err)
+			}
+			fresh := &Config{Size: size, Key: []byte("key"), Salt: []byte("salt")}
+			if tree != nil {
+				fresh.Tree = &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 3}
+			}
+			h2, _ := NewXOF(fresh)
+			h.Write([]byte("abc"))
+			h2.Write([]byte("abc"))
+			got, want := make([]byte, size), make([]byte, size)
+			h.Read(got)
+			h2.Read(want)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%d: output differs from fresh config", size)
+			}
+			if tree != nil && tree.NodeOffset != 3 {
+				t.Fatalf("%d: config tree was modified", size)
+			}
+		}
+	}
+}
+
+func TestSum32Sum64(t *testing.T) {
+	h, _ := NewXOFSize(100)
+	h.Write([]byte("abc"))
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), nil)
+	s32, err := h.Sum32()
+	if err != nil || !bytes.Equal(s32[:], want[:32]) {
+		t.Errorf("Sum32: expected %x, got %x (err = %v)", want[:32], s32, err)
+	}
+	s64, err := h.Sum64()
+	if err != nil || !bytes.Equal(s64[:], want[:64]) {
+		t.Errorf("Sum64: expected %x, got %x (err = %v)", want[:64], s64, err)
+	}
+	if h.Remaining() != 100 {
+		t.Errorf("Sum32 or Sum64 changed read position")
+	}
+	h, _ = NewXOFSize(63)
+	if _, err := h.Sum64(); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+}
+
+func BenchmarkSum32(b *testing.B) {
+	h, _ := NewXOFSize(32)
+	h.Write([]byte("abc"))
+	b.SetBytes(32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Sum32()
+	}
+}
+
+func TestNextBlock(t *testing.T) {
+	h, _ := NewXOFSize(100)
+	h.Write([]byte("abc"))
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), nil)
+	var got []byte
+	dst := make([]byte, BlockSize)
+	for {
+		n, err := h.NextBlock(dst)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		got = append(got, dst[:n]...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+
+	h, _ = NewXOFSize(100)
+	h.Write([]byte("abc"))
+	if _, err := h.NextBlock(make([]byte, BlockSize-1)); err != ErrBlockLength {
+		t.Errorf("expected ErrBlockLength, got %v", err)
+	}
+	h.ReadByte()
+	if _, err := h.NextBlock(dst); err != ErrBlockAlign {
+		t.Errorf("expected ErrBlockAlign, got %v", err)
+	}
+	h.Read(make([]byte, BlockSize-1))
+	h.NextBlock(dst)
+	b := make([]byte, 100-2*BlockSize)
+	if _, err := io.ReadFull(h, b); err != nil || !bytes.Equal(b, want[2*BlockSize:]) {
+		t.Errorf("Read after NextBlock returned wrong output (err = %v)", err)
+	}
+}
+
+func TestReadBackendErrorRecovery(t *testing.T) {
+	want := make([]byte, 200)
+	Sum(want, []byte("abc"), nil)
+	for _, k := range []int{0, 1, 2, 6} {
+		// Call 1 creates the root hash, call k+2 creates block k.
+		b := testBackend{failAt: k + 2}
+		h, _ := NewXOF(&Config{Size: 200, Backend: b.New})
+		h.Write([]byte("abc"))
+		got := make([]byte, 200)
+		nn, err := h.Read(got[:5])
+		if k == 0 {
+			if nn != 0 || err != errBackend {
+				t.Fatalf("block %d: expected 0, errBackend; got %d, %v", k, nn, err)
+			}
+		} else {
+			n, err := h.Read(got[5:])
+			nn += n
+			if nn != k*BlockSize || err != errBackend {
+				t.Fatalf("block %d: expected %d, errBackend; got %d, %v", k, k*BlockSize, nn, err)
+			}
+		}
+		if h.Remaining() != 200-nn {
+			t.Errorf("block %d: expected Remaining() = %d, got %d", k, 200-nn, h.Remaining())
+		}
+		// Retry after a transient error continues output.
+		if _, err := io.ReadFull(h, got[nn:]); err != nil {
+			t.Fatalf("block %d: error on retry: %s", k, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("block %d: output corrupted after error", k)
+		}
+	}
+}
+
+func TestSeekBackendErrorRecovery(t *testing.T) {
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), nil)
+	for i, seek := range []func(h *XOF) error{
+		func(h *XOF) error { _, err := h.Seek(40, io.SeekStart); return err },
+		func(h *XOF) error { _, err := h.Discard(40); return err },
+		func(h *XOF) error { return h.RestoreCursor([]byte{0, 100, 0, 40}) },
+	} {
+		// Call 1 creates the root hash, call 2 creates block 0 for
+		// the first read, call 3 creates block 1 for seeking.
+		b := testBackend{failAt: 3}
+		h, _ := NewXOF(&Config{Size: 100, Backend: b.New})
+		h.Write([]byte("abc"))
+		got := make([]byte, 5)
+		h.Read(got)
+		if err := seek(h); err != errBackend {
+			t.Fatalf("%d: expected errBackend, got %v", i, err)
+		}
+		if h.Remaining() != 95 {
+			t.Errorf("%d: position changed after error: Remaining() = %d", i, h.Remaining())
+		}
+		rest := make([]byte, 95)
+		if _, err := io.ReadFull(h, rest); err != nil {
+			t.Fatalf("%d: error reading: %s", i, err)
+		}
+		if !bytes.Equal(append(got, rest...), want) {
+			t.Errorf("%d: output corrupted after error", i)
+		}
+	}
+}
+
+func TestFinalized(t *testing.T) {
+	h, _ := NewXOF(nil)
+	h.Write([]byte("abc"))
+	if h.Finalized() {
+		t.Errorf("finalized before reading")
+	}
+	if _, err := h.Write([]byte("def")); err != nil {
+		t.Errorf("Finalized prevented writing: %s", err)
+	}
+	h.ReadByte()
+	if !h.Finalized() {
+		t.Errorf("not finalized after reading")
+	}
+	h.Rewind()
+	if h.Finalized() {
+		t.Errorf("finalized after Rewind")
+	}
+}
+
+func TestAbsorbAll(t *testing.T) {
+	h, _ := NewXOFSize(64)
+	if err := AbsorbAll(h, []byte("ab"), "cd", bytes.NewReader([]byte("ef")), []byte(nil)); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	got := make([]byte, 64)
+	h.Read(got)
+	want := make([]byte, 64)
+	Sum(want, []byte("abcdef"), nil)
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+	if err := AbsorbAll(h, []byte("x")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	h, _ = NewXOF(nil)
+	if err := AbsorbAll(h, "a", 1, "b"); err != ErrSourceType {
+		t.Errorf("expected ErrSourceType, got %v", err)
+	}
+}
+
+func TestReadProgress(t *testing.T) {
+	for _, size := range []int{1, 31, 32, 33, 64, 100, UnknownSize} {
+		h, _ := NewXOFSize(size)
+		want := make([]byte, size)
+		Sum(want, nil, nil)
+		got := make([]byte, 0, size)
+		b := make([]byte, 1)
+		for {
+			n, err := h.Read(b)
+			if n == 0 && err == nil {
+				t.Fatalf("size %d: Read returned 0, nil at %d", size, len(got))
+			}
+			got = append(got, b[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("size %d: error: %s", size, err)
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("size %d: wrong output", size)
+		}
+	}
+}
+
+func TestNoShortLastBlock(t *testing.T) {
+	c := &Config{Size: 70, Key: []byte("key"), NoShortLastBlock: true}
+	h, _ := NewXOF(c)
+	h.Write([]byte("abc"))
+	got := make([]byte, 70)
+	if _, err := io.ReadFull(h, got); err != nil {
+		t.Fatal(err)
+	}
+
+	std := make([]byte, 70)
+	Sum(std, []byte("abc"), &Config{Size: 70, Key: []byte("key")})
+	if !bytes.Equal(got[:64], std[:64]) {
+		t.Errorf("full blocks differ from standard output")
+	}
+	if bytes.Equal(got[64:], std[64:]) {
+		t.Errorf("last block is the same as standard output")
+	}
+	// Last block is a truncated full-size digest.
+	root, _ := h.RootDigest()
+	b, _ := blake2s.New(&blake2s.Config{
+		Size: blake2s.Size,
+		Tree: &blake2s.Tree{LeafSize: blake2s.Size, InnerHashSize: blake2s.Size, NodeOffset: 70<<32 + 2},
+	})
+	b.Write(root)
+	if want := b.Sum(nil)[:6]; !bytes.Equal(got[64:], want) {
+		t.Errorf("last block: expected %x, got %x", want, got[64:])
+	}
+
+	for _, off := range []int64{0, 60, 64, 69} {
+		p := make([]byte, 70-off)
+		if _, err := h.ReadAt(p, off); err != nil || !bytes.Equal(p, got[off:]) {
+			t.Errorf("ReadAt(%d): wrong output (err = %v)", off, err)
+		}
+	}
+	last := make([]byte, 6)
+	if err := h.ReadBlockAt(last, 2); err != nil || !bytes.Equal(last, got[64:]) {
+		t.Errorf("ReadBlockAt: wrong output (err = %v)", err)
+	}
+}
+
+func TestNodeOffsetFor(t *testing.T) {
+	tree := &blake2s.Tree{Fanout: 2, MaxDepth: 2, LeafSize: 64, NodeOffset: 7, InnerHashSize: 32}
+	for _, v := range []struct {
+		size int
+		tree *blake2s.Tree
+		want uint64
+	}{
+		{0, nil, 0xffff << 32},
+		{1, nil, 1 << 32},
+		{32, nil, 32 << 32},
+		{MaxSize, nil, 0xffff << 32},
+		{100, tree, 100<<32 + 7},
+	} {
+		if got := NodeOffsetFor(v.size, v.tree); got != v.want {
+			t.Errorf("size %d: expected %#x, got %#x", v.size, v.want, got)
+		}
+
+		// Root digest must match BLAKE2s with this node offset.
+		c := &Config{Size: uint16(v.size), Key: []byte("key"), Tree: v.tree}
+		h, _ := NewXOF(c)
+		h.Write([]byte("abc"))
+		got, _ := h.RootDigest()
+		rt := blake2s.Tree{Fanout: 1, MaxDepth: 1}
+		if v.tree != nil {
+			rt = *v.tree
+		}
+		rt.NodeOffset = v.want
+		r, _ := blake2s.New(&blake2s.Config{Size: blake2s.Size, Key: []byte("key"), Tree: &rt})
+		r.Write([]byte("abc"))
+		if want := r.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("size %d: root digest doesn't match node offset", v.size)
+		}
+	}
+	for _, size := range []int{-1, UnknownSize + 1, 1 << 32} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("size %d: expected panic", size)
+				}
+			}()
+			NodeOffsetFor(size, nil)
+		}()
+	}
+}
+
+func TestFlush(t *testing.T) {
+	want := make([]byte, 100)
+	Sum(want, []byte("abc"), &Config{Size: 100, Key: []byte("key")})
+
+	h, _ := NewXOF(&Config{Size: 100, Key: []byte("key")})
+	h.Write([]byte("abc"))
+	got := make([]byte, 100)
+	h.Read(got[:40])
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err)
+	}
+	if !bytes.Equal(h.x[:8], make([]byte, 8)) {
+		t.Errorf("consumed output is left in buffer: %x", h.x[:8])
+	}
+	h.Read(got[40:])
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong output after Flush")
+	}
+
+	// Backend error leaves the XOF usable.
+	b := testBackend{failAt: 4}
+	h, _ = NewXOF(&Config{Size: 100, Key: []byte("key"), Backend: b.New})
+	h.Write([]byte("abc"))
+	h.Read(got[:40])
+	if err := h.Flush(); err != errBackend {
+		t.Fatalf("expected errBackend, got %v", err)
+	}
+	if _, err := io.ReadFull(h, got[40:]); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("wrong output after failed Flush (err = %v)", err)
+	}
+
+	var zero [blake2s.Size]byte
+	for _, reset := range []func(h *XOF){
+		(*XOF).Reset,
+		func(h *XOF) { h.ResetWithKey([]byte("other")) },
+	} {
+		h, _ := NewXOF(&Config{Size: 100, Key: []byte("key")})
+		h.Write([]byte("abc"))
+		h.WriteN(io.Discard, 40)
+		reset(h)
+		if h.x != zero {
+			t.Errorf("output buffer is not cleared after reset: %x", h.x)
+		}
+		if h.h0 != zero {
+			t.Errorf("root digest is not cleared after reset: %x", h.h0)
+		}
+		if !bytes.Equal(h.buf, make([]byte, len(h.buf))) {
+			t.Errorf("scratch buffer is not cleared after reset")
+		}
+	}
+}
+
+func TestTapErrorsSurfaced(t *testing.T) {
+	c := func() *Config { return &Config{Tap: &errWriter{n: 0}} }
+	if err := Sum(make([]byte, 32), []byte("data"), c()); err == nil {
+		t.Errorf("Sum: expected error")
+	}
+	if err := SumBatch([][]byte{make([]byte, 32)}, [][]byte{[]byte("data")}, c()); err == nil {
+		t.Errorf("SumBatch: expected error")
+	}
+	if err := CheckVector(c(), []byte("data"), make([]byte, 32)); err == nil || err == ErrVectorMismatch {
+		t.Errorf("CheckVector: expected write error, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "out")
+	if err := ExpandToFile(path, []byte("data"), c()); err == nil {
+		t.Errorf("ExpandToFile: expected error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("ExpandToFile: file exists after error")
+	}
+	r, _ := NewSumReader(bytes.NewReader([]byte("data")), c())
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("SumReader: expected error")
+	}
+}
+
+func TestSumPanicsOnError(t *testing.T) {
+	b := testBackend{failAt: 2}
+	for i, c := range []*Config{
+		{Size: 32, MinInput: 100},
+		{Size: 32, Strict: true},
+		{Size: 32, Backend: b.New},
+	} {
+		h, _ := NewXOF(c)
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d: expected panic", i)
+				}
+			}()
+			h.Sum(nil)
+		}()
+	}
+}
+
+func TestRewindUnmarshaled(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 64})
+	h.Write([]byte("hello"))
+	h.Finalize()
+	state, _ := h.MarshalBinary()
+
+	h2, _ := NewXOF(&Config{Size: 64})
+	h2.UnmarshalBinary(state)
+	if err := h2.Rewind(); err != ErrNoRootState {
+		t.Errorf("expected ErrNoRootState after unmarshaling, got %v", err)
+	}
+	if _, err := h2.Write([]byte(" world")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	c, _ := h2.Clone()
+	if err := c.Rewind(); err != ErrNoRootState {
+		t.Errorf("expected ErrNoRootState for clone, got %v", err)
+	}
+
+	// Rewinding is possible after unmarshaling unfinalized state.
+	h, _ = NewXOF(&Config{Size: 64})
+	h.Write([]byte("hello"))
+	state, _ = h.MarshalBinary()
+	h2.UnmarshalBinary(state)
+	h2.Finalize()
+	if err := h2.Rewind(); err != nil {
+		t.Fatalf("Rewind: %s", err)
+	}
+	h2.Write([]byte(" world"))
+	want := make([]byte, 64)
+	Sum(want, []byte("hello world"), nil)
+	got := make([]byte, 64)
+	h2.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong output after Rewind")
+	}
+}
+
 var goldenXOF = []struct {
 	in, key, out string
 }{
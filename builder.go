@@ -0,0 +1,38 @@
+package blake2xs
+
+// Builder creates XOFs of different sizes sharing the same parameters.
+type Builder struct {
+	c Config
+}
+
+// NewBuilder returns a new Builder with parameters from c, ignoring c.Size.
+// Parameters are copied, so later changes to c don't affect the builder.
+func NewBuilder(c *Config) (*Builder, error) {
+	var b Builder
+	if c != nil {
+		b.c = *c
+		b.c.Key = append([]byte(nil), c.Key...)
+		b.c.Salt = append([]byte(nil), c.Salt...)
+		b.c.Person = append([]byte(nil), c.Person...)
+		if c.Tree != nil {
+			tree := *c.Tree
+			b.c.Tree = &tree
+		}
+	}
+	b.c.Size = 0
+	if err := b.c.Validate(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// NewWithSize returns a new XOF with the builder's parameters and the given
+// output size. If size is zero, output size is UnknownSize.
+func (b *Builder) NewWithSize(size int) (*XOF, error) {
+	if !validSize(size) {
+		return nil, ErrSize
+	}
+	c := b.c
+	c.Size = uint16(size)
+	return NewXOF(&c)
+}
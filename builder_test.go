@@ -0,0 +1,51 @@
+package blake2xs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dchest/blake2s"
+)
+
+func TestBuilder(t *testing.T) {
+	c := &Config{
+		Key:    []byte("key"),
+		Salt:   []byte("salt"),
+		Person: []byte("person"),
+		Tree:   &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 7},
+	}
+	b, err := NewBuilder(c)
+	if err != nil {
+		t.Fatalf("error creating: %s", err)
+	}
+	want := make(map[int][]byte)
+	for _, size := range []int{1, 32, 100} {
+		cc := *c
+		cc.Size = uint16(size)
+		want[size] = make([]byte, size)
+		Sum(want[size], []byte("abc"), &cc)
+	}
+
+	// Modifying the original config must not affect the builder.
+	c.Key[0] = 'x'
+	c.Tree.NodeOffset = 0
+
+	for _, size := range []int{1, 32, 100} {
+		h, err := b.NewWithSize(size)
+		if err != nil {
+			t.Fatalf("%d: error: %s", size, err)
+		}
+		h.Write([]byte("abc"))
+		got := make([]byte, size)
+		h.Read(got)
+		if !bytes.Equal(got, want[size]) {
+			t.Errorf("%d: expected %x, got %x", size, want[size], got)
+		}
+	}
+	if _, err := b.NewWithSize(UnknownSize + 1); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+	if _, err := NewBuilder(&Config{Salt: make([]byte, 9)}); err != ErrSaltSize {
+		t.Errorf("expected ErrSaltSize, got %v", err)
+	}
+}
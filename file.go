@@ -0,0 +1,42 @@
+package blake2xs
+
+import (
+	"bufio"
+	"os"
+)
+
+// ExpandToFile computes the XOF of data with config c and writes its whole
+// output to a new file with the given path. Config may be nil.
+//
+// The file is created with permissions 0600, since output may be used as key
+// material; ExpandToFile fails if the file already exists. The file is synced
+// before closing. On error, the partially written file is removed.
+func ExpandToFile(path string, data []byte, c *Config) (err error) {
+	x, err := NewXOF(c)
+	if err != nil {
+		return err
+	}
+	if _, err := x.Write(data); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if _, err := x.WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,38 @@
+package blake2xs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExpandToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	c := &Config{Size: 1000, Key: []byte("key")}
+	if err := ExpandToFile(path, []byte("data"), c); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 1000)
+	Sum(want, []byte("data"), c)
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong file contents")
+	}
+	if runtime.GOOS != "windows" {
+		fi, _ := os.Stat(path)
+		if perm := fi.Mode().Perm(); perm&0077 != 0 {
+			t.Errorf("file is accessible by others: %v", perm)
+		}
+	}
+	if err := ExpandToFile(path, []byte("data"), c); err == nil {
+		t.Errorf("expected error for existing file")
+	}
+	if err := ExpandToFile(path+"2", nil, &Config{Salt: make([]byte, 9)}); err != ErrSaltSize {
+		t.Errorf("expected ErrSaltSize, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package blake2xs
+
+import (
+	"bytes"
+	"io"
+)
+
+// RoundTripCheck creates XOF with config derived from configSeed, writes
+// input into it, and checks that reading the whole output at once, reading
+// it in small chunks, and reading it with ReadAt produce the same output.
+// It returns ErrRoundTrip if outputs differ. It is intended to be called
+// from fuzz tests, and accepts any seed.
+//
+// The seed is consumed in order: two bytes of output size (big-endian,
+// zero is UnknownSize), one byte of key length modulo 33, key, one byte
+// of salt length modulo 9, salt, one byte of personalization length
+// modulo 9, personalization. Remaining bytes are chunk sizes for reads
+// (modulo 64, plus 1), which are reused cyclically. Missing bytes are
+// zeros.
+func RoundTripCheck(configSeed, input []byte) error {
+	s := configSeed
+	next := func(n int) []byte {
+		b := make([]byte, n)
+		s = s[copy(b, s):]
+		return b
+	}
+	sz := next(2)
+	c := &Config{Size: uint16(sz[0])<<8 | uint16(sz[1])}
+	c.Key = next(int(next(1)[0]) % 33)
+	c.Salt = next(int(next(1)[0]) % 9)
+	c.Person = next(int(next(1)[0]) % 9)
+	chunks := s
+	if len(chunks) == 0 {
+		chunks = []byte{0}
+	}
+
+	x, err := NewXOF(c)
+	if err != nil {
+		return err
+	}
+	x.Write(input)
+	if err := x.Finalize(); err != nil {
+		return err
+	}
+	size := x.Size()
+	y, err := x.Clone()
+	if err != nil {
+		return err
+	}
+
+	whole := make([]byte, size)
+	if _, err := io.ReadFull(x, whole); err != nil {
+		return err
+	}
+	if n, err := x.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		return ErrRoundTrip
+	}
+
+	small := make([]byte, 0, size)
+	for i := 0; len(small) < size; i++ {
+		n := int(chunks[i%len(chunks)])%64 + 1
+		if n > size-len(small) {
+			n = size - len(small)
+		}
+		m, err := y.Read(small[len(small) : len(small)+n])
+		if err != nil {
+			return err
+		}
+		small = small[:len(small)+m]
+	}
+	if !bytes.Equal(small, whole) {
+		return ErrRoundTrip
+	}
+
+	at := make([]byte, size)
+	for off, i := 0, 0; off < size; i++ {
+		n := int(chunks[i%len(chunks)])%64 + 1
+		if n > size-off {
+			n = size - off
+		}
+		if _, err := x.ReadAt(at[off:off+n], int64(off)); err != nil {
+			return err
+		}
+		off += n
+	}
+	if !bytes.Equal(at, whole) {
+		return ErrRoundTrip
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package blake2xs
+
+import "testing"
+
+var roundTripSeeds = []struct{ seed, input string }{
+	{"", ""},
+	{"\x00\x01", "abc"},
+	{"\x00\x21\x03key\x04salt\x06person\x3f", "abc"},
+	{"\x03\xe8\x20" + string(make([]byte, 32)) + "\x08saltsalt\x08personal\x00\x1f\x20\x21", "input"},
+	{"\xff\xff\xff\xff\xff\xff\xff\xff", string(make([]byte, 1000))},
+}
+
+func TestRoundTripCheck(t *testing.T) {
+	for i, v := range roundTripSeeds {
+		if err := RoundTripCheck([]byte(v.seed), []byte(v.input)); err != nil {
+			t.Errorf("%d: %s", i, err)
+		}
+	}
+}
+
+func FuzzRoundTripCheck(f *testing.F) {
+	for _, v := range roundTripSeeds {
+		f.Add([]byte(v.seed), []byte(v.input))
+	}
+	f.Fuzz(func(t *testing.T, seed, input []byte) {
+		if err := RoundTripCheck(seed, input); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package blake2xs
+
+import (
+	"hash"
+
+	"github.com/dchest/blake2s"
+)
+
+type digest struct {
+	x *XOF
+}
+
+// New returns a new hash.Hash computing the XOF with the given output size
+// and key. Key may be nil. Sum appends size bytes of output.
+func New(size int, key []byte) (hash.Hash, error) {
+	if size == 0 || !validSize(size) {
+		return nil, ErrSize
+	}
+	x, err := NewXOF(&Config{Size: uint16(size), Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return &digest{x: x}, nil
+}
+
+func (d *digest) Write(p []byte) (nn int, err error) { return d.x.Write(p) }
+
+func (d *digest) Sum(b []byte) []byte {
+	// Compute output from a copy of the root digest without finalizing.
+	t := &XOF{
+		nh:   d.x.nh,
+		oc:   d.x.oc,
+		fin:  true,
+		size: d.x.size,
+	}
+	d.x.rh.Sum(t.h0[:0])
+	n := len(b)
+	b = append(b, make([]byte, d.x.size)...)
+	t.ReadAt(b[n:], 0)
+	return b
+}
+
+func (d *digest) Reset() { d.x.Reset() }
+
+func (d *digest) Size() int { return d.x.size }
+
+func (d *digest) BlockSize() int { return blake2s.BlockSize }
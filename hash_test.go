@@ -0,0 +1,42 @@
+package blake2xs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for i, v := range goldenXOF {
+		in, _ := hex.DecodeString(v.in)
+		key, _ := hex.DecodeString(v.key)
+		h, err := New(len(v.out)/2, key)
+		if err != nil {
+			t.Fatalf("%d: error creating: %s", i, err)
+		}
+		if h.Size() != len(v.out)/2 {
+			t.Errorf("%d: expected Size() = %d, got %d", i, len(v.out)/2, h.Size())
+		}
+		h.Write(in[:len(in)/2])
+		h.Sum(nil) // must not change state
+		h.Write(in[len(in)/2:])
+		prefix := []byte("prefix")
+		sum := h.Sum(prefix)
+		if !bytes.Equal(sum[:len(prefix)], prefix) {
+			t.Errorf("%d: Sum didn't append to prefix", i)
+		}
+		if hex.EncodeToString(sum[len(prefix):]) != v.out {
+			t.Errorf("%d: expected %s, got %x", i, v.out, sum[len(prefix):])
+		}
+		h.Reset()
+		h.Write(in)
+		if hex.EncodeToString(h.Sum(nil)) != v.out {
+			t.Errorf("%d: wrong output after reset", i)
+		}
+	}
+	for _, size := range []int{-1, 0, UnknownSize + 1} {
+		if _, err := New(size, nil); err != ErrSize {
+			t.Errorf("expected error for size %d", size)
+		}
+	}
+}
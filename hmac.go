@@ -0,0 +1,70 @@
+package blake2xs
+
+import (
+	"hash"
+
+	"github.com/dchest/blake2s"
+)
+
+// HMAC with BLAKE2Xs follows RFC 2104 with block size of 64 bytes
+// (blake2s.BlockSize), and H(m) being unkeyed BLAKE2Xs of m with output
+// size equal to the tag size:
+//
+//	K0  = K padded with zeros to 64 bytes, where K is the key,
+//	      or its unkeyed BLAKE2Xs-256 digest if the key is longer
+//	      than 64 bytes
+//	tag = H((K0 ^ opad) || H((K0 ^ ipad) || message))
+//
+// where ipad and opad are 64 bytes of 0x36 and 0x5c.
+//
+// HMAC is provided for compatibility with requirements that mandate it.
+// Otherwise, NewMAC, which uses the key parameter of BLAKE2s, should be
+// preferred.
+
+type hmacDigest struct {
+	inner, outer hash.Hash
+	ipad, opad   [blake2s.BlockSize]byte
+}
+
+// NewHMAC returns a new hash.Hash computing HMAC of the given size with key
+// using BLAKE2Xs. Sum appends size bytes of tag.
+func NewHMAC(key []byte, size int) (hash.Hash, error) {
+	inner, err := New(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	outer, _ := New(size, nil)
+	h := &hmacDigest{inner: inner, outer: outer}
+	if len(key) > blake2s.BlockSize {
+		kh, _ := New(blake2s.Size, nil)
+		kh.Write(key)
+		key = kh.Sum(nil)
+	}
+	copy(h.ipad[:], key)
+	copy(h.opad[:], key)
+	for i := range h.ipad {
+		h.ipad[i] ^= 0x36
+		h.opad[i] ^= 0x5c
+	}
+	h.inner.Write(h.ipad[:])
+	return h, nil
+}
+
+func (h *hmacDigest) Write(p []byte) (nn int, err error) { return h.inner.Write(p) }
+
+func (h *hmacDigest) Sum(b []byte) []byte {
+	in := h.inner.Sum(nil)
+	h.outer.Reset()
+	h.outer.Write(h.opad[:])
+	h.outer.Write(in)
+	return h.outer.Sum(b)
+}
+
+func (h *hmacDigest) Reset() {
+	h.inner.Reset()
+	h.inner.Write(h.ipad[:])
+}
+
+func (h *hmacDigest) Size() int { return h.inner.Size() }
+
+func (h *hmacDigest) BlockSize() int { return blake2s.BlockSize }
@@ -0,0 +1,76 @@
+package blake2xs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+var hmacVectors = []struct {
+	key, msg string
+	out      string
+}{
+	{"key", "The quick brown fox jumps over the lazy dog", "1bb4766d6edf23a63109583a98c5d67d3e19e292fc0439b70dec8949ca179fa3"},
+	{"", "", "c8"},
+	{strings.Repeat("k", 100), "message", "a3f35eeef1a473daf4c1e36918357cacb24331f4c64669cc43d3a0fa380fa31ad8cbab17fc0ca999a75a8b4d242195faf23e3ab9ddce3957e87d22d4448f20b0a3526deca76ee3223b641b8de70cbe52f7ee8629d4fb4f089907ccb5ee4562763cde49fb"},
+}
+
+// hmacSum computes HMAC directly from its definition.
+func hmacSum(key, msg []byte, size int) []byte {
+	if len(key) > 64 {
+		k := make([]byte, 32)
+		Sum(k, key, nil)
+		key = k
+	}
+	ipad := bytes.Repeat([]byte{0x36}, 64)
+	opad := bytes.Repeat([]byte{0x5c}, 64)
+	for i, b := range key {
+		ipad[i] ^= b
+		opad[i] ^= b
+	}
+	in := make([]byte, size)
+	Sum(in, append(ipad, msg...), nil)
+	out := make([]byte, size)
+	Sum(out, append(opad, in...), nil)
+	return out
+}
+
+func TestHMAC(t *testing.T) {
+	for i, v := range hmacVectors {
+		want, _ := hex.DecodeString(v.out)
+		h, err := NewHMAC([]byte(v.key), len(want))
+		if err != nil {
+			t.Fatalf("%d: error creating: %s", i, err)
+		}
+		h.Write([]byte(v.msg))
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d: expected %x, got %x", i, want, got)
+		}
+		if got := hmacSum([]byte(v.key), []byte(v.msg), len(want)); !bytes.Equal(got, want) {
+			t.Errorf("%d: definition: expected %x, got %x", i, want, got)
+		}
+		h.Reset()
+		h.Write([]byte(v.msg))
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d: after reset: expected %x, got %x", i, want, got)
+		}
+	}
+
+	// Sum doesn't change the state.
+	h, _ := NewHMAC([]byte("key"), 16)
+	h.Write([]byte("hello, "))
+	h.Sum(nil)
+	h.Write([]byte("world"))
+	if got, want := h.Sum([]byte("x")), append([]byte("x"), hmacSum([]byte("key"), []byte("hello, world"), 16)...); !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+	if h.Size() != 16 || h.BlockSize() != 64 {
+		t.Errorf("wrong Size or BlockSize")
+	}
+	for _, size := range []int{0, -1, UnknownSize + 1} {
+		if _, err := NewHMAC(nil, size); err != ErrSize {
+			t.Errorf("size %d: expected ErrSize, got %v", size, err)
+		}
+	}
+}
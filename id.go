@@ -0,0 +1,18 @@
+package blake2xs
+
+// ID128 returns a 16-byte identifier of data, which is the XOF of data
+// with config c and output size 16. Config may be nil. If config size is not
+// zero, it must be 16.
+//
+// Identifiers depend on all config parameters: changing key, salt,
+// personalization or tree parameters changes all identifiers.
+func ID128(c *Config, data []byte) (id [16]byte, err error) {
+	err = Sum(id[:], data, c)
+	return
+}
+
+// ID256 is like ID128, but returns a 32-byte identifier.
+func ID256(c *Config, data []byte) (id [32]byte, err error) {
+	err = Sum(id[:], data, c)
+	return
+}
@@ -0,0 +1,33 @@
+package blake2xs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestID(t *testing.T) {
+	c := &Config{Key: []byte("key")}
+	id16, err := ID128(c, []byte("user@example.com"))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	id32, err := ID256(c, []byte("user@example.com"))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want16, want32 := make([]byte, 16), make([]byte, 32)
+	Sum(want16, []byte("user@example.com"), c)
+	Sum(want32, []byte("user@example.com"), c)
+	if !bytes.Equal(id16[:], want16) {
+		t.Errorf("ID128: expected %x, got %x", want16, id16)
+	}
+	if !bytes.Equal(id32[:], want32) {
+		t.Errorf("ID256: expected %x, got %x", want32, id32)
+	}
+	if other, _ := ID128(&Config{Key: []byte("other")}, []byte("user@example.com")); other == id16 {
+		t.Errorf("identifiers with different keys are equal")
+	}
+	if _, err := ID128(&Config{Size: 32}, nil); err != ErrSizeMismatch {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package blake2xs
+
+import "encoding/binary"
+
+// DeriveKey derives a key of len(out) bytes from the given secret key, salt
+// and context, and puts it into out.
+//
+// The derivation is the XOF with Key set to key, Salt set to salt, Person set
+// to context, Size set to len(out), and no input. Salt and context must be at
+// most 8 bytes; shorter values are padded with zeros.
+func DeriveKey(out, key, salt, context []byte) error {
+	return Sum(out, nil, &Config{
+		Key:    key,
+		Salt:   salt,
+		Person: context,
+	})
+}
+
+// Expand expands the pseudorandom key prk into len(out) bytes bound to info,
+// similar to HKDF-Expand, and puts them into out.
+//
+// The expansion is the XOF with Key set to prk, Size set to len(out), no salt
+// or personalization, and info as input. Prk must be at most 32 bytes.
+func Expand(out, prk, info []byte) error {
+	return Sum(out, info, &Config{Key: prk})
+}
+
+// DeriveKeys derives len(outs) independent keys from the given master key,
+// filling each of outs.
+//
+// The i-th key is derived with DeriveKey with no salt and context set to i
+// encoded as 8-byte little-endian integer, that is, it is the XOF with Key set
+// to master, Person set to the encoded index, Size set to len(outs[i]), and no
+// input. Keys are not substrings of the same output.
+func DeriveKeys(master []byte, outs ...[]byte) error {
+	var context [8]byte
+	for i, out := range outs {
+		binary.LittleEndian.PutUint64(context[:], uint64(i))
+		if err := DeriveKey(out, master, nil, context[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package blake2xs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	for i, v := range []struct {
+		key, salt, context, out string
+	}{
+		{
+			"secret key", "", "",
+			"671e834dcea84f27cdfc78939a97e2817773c770f7331c92cae8d94ee0c672d5",
+		},
+		{
+			"secret key", "salt", "enc",
+			"77fddd7bf5332dfbf6e198c9d63d92b9",
+		},
+		{
+			"0123456789abcdef0123456789abcdef", "saltsalt", "context",
+			"f3866d4fa6e707ce472a98999d017d358b9d44873c9d2ab3348194048f5b6f6223ba21226bce97f8aa0b423915936f95b72303a4bd585afc4981a6a9ba00dbef",
+		},
+	} {
+		out := make([]byte, len(v.out)/2)
+		if err := DeriveKey(out, []byte(v.key), []byte(v.salt), []byte(v.context)); err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if hex.EncodeToString(out) != v.out {
+			t.Errorf("%d: expected %s, got %x", i, v.out, out)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	for i, v := range []struct {
+		prk, info, out string
+	}{
+		{
+			"pseudorandom key", "",
+			"4f9a472780ee803e6e94abd8ef3612da69f383357d77f9e4b7ce1ba98a2df7b1",
+		},
+		{
+			"pseudorandom key", "encryption",
+			"fb38e0d0fb4a53e5e2a6e568d0dd275a",
+		},
+		{
+			"pseudorandom key", "authentication",
+			"0bbae213194dc7862a4c419e28969cb62d24f75ddabc09cafdeaccf5b039c9ff9ffc295d128c9270b935bb44c724cc53",
+		},
+	} {
+		out := make([]byte, len(v.out)/2)
+		if err := Expand(out, []byte(v.prk), []byte(v.info)); err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if hex.EncodeToString(out) != v.out {
+			t.Errorf("%d: expected %s, got %x", i, v.out, out)
+		}
+	}
+}
+
+func TestDeriveKeys(t *testing.T) {
+	master := []byte("master key")
+	enc, mac := make([]byte, 32), make([]byte, 32)
+	if err := DeriveKeys(master, enc, mac); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if bytes.Equal(enc, mac) {
+		t.Errorf("derived keys are equal")
+	}
+	for i, out := range [][]byte{enc, mac} {
+		want := make([]byte, len(out))
+		if err := DeriveKey(want, master, nil, []byte{byte(i), 0, 0, 0, 0, 0, 0, 0}); err != nil {
+			t.Fatalf("%d: error: %s", i, err)
+		}
+		if !bytes.Equal(out, want) {
+			t.Errorf("%d: expected %x, got %x", i, want, out)
+		}
+	}
+	if err := DeriveKeys(make([]byte, 33), enc); err != ErrKeySize {
+		t.Errorf("expected ErrKeySize, got %v", err)
+	}
+}
+
+func benchmarkDeriveKey(b *testing.B, size int) {
+	key := []byte("secret key")
+	out := make([]byte, size)
+	b.Run("Sized", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			DeriveKey(out, key, nil, nil)
+		}
+	})
+	b.Run("Partial", func(b *testing.B) {
+		// First read shorter than a block from XOF of UnknownSize.
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h, _ := NewXOF(&Config{Key: key})
+			h.Read(out)
+		}
+	})
+}
+
+func BenchmarkDeriveKey16(b *testing.B) { benchmarkDeriveKey(b, 16) }
+func BenchmarkDeriveKey24(b *testing.B) { benchmarkDeriveKey(b, 24) }
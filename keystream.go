@@ -0,0 +1,99 @@
+package blake2xs
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/dchest/blake2s"
+)
+
+// Keystream is an unbounded keystream generator, which extends BLAKE2Xs
+// output beyond UnknownSize bytes. Only its first UnknownSize bytes are
+// BLAKE2Xs output; the rest is specific to this package.
+//
+// The keystream is a concatenation of segments of UnknownSize bytes each.
+// Segment 0 is the BLAKE2Xs output of UnknownSize bytes for the config
+// with no input, with root digest h0. Segment j > 0 is the BLAKE2Xs output
+// of UnknownSize bytes computed from the root digest
+//
+//	BLAKE2s-256(h0 || j as 8-byte little-endian integer)
+//
+// instead of h0, with the same output hash parameters.
+type Keystream struct {
+	x    *XOF
+	root [blake2s.Size]byte
+	seg  uint64
+}
+
+// NewKeystream returns a new unbounded keystream generator for the given
+// config. Config size must be zero or UnknownSize.
+func NewKeystream(c *Config) (*Keystream, error) {
+	var cc Config
+	if c != nil {
+		if c.Size != 0 && c.Size != UnknownSize {
+			return nil, ErrSize
+		}
+		cc = *c
+	}
+	// Keystream has no input by definition.
+	cc.Strict = false
+	x, err := NewXOF(&cc)
+	if err != nil {
+		return nil, err
+	}
+	if err := x.finalize(); err != nil {
+		return nil, err
+	}
+	return &Keystream{x: x, root: x.h0}, nil
+}
+
+// Read reads len(p) bytes of keystream into p. It always returns len(p), nil
+// unless the backend fails.
+func (k *Keystream) Read(p []byte) (nn int, err error) {
+	for nn < len(p) {
+		n, err := k.x.Read(p[nn:])
+		nn += n
+		if err == io.EOF {
+			k.reseed()
+			continue
+		}
+		if err != nil {
+			return nn, err
+		}
+	}
+	return nn, nil
+}
+
+// reseed switches output to the next segment.
+func (k *Keystream) reseed() {
+	k.seg++
+	var in [blake2s.Size + 8]byte
+	copy(in[:], k.root[:])
+	binary.LittleEndian.PutUint64(in[blake2s.Size:], k.seg)
+	k.x.resetOutput()
+	k.x.h0 = blake2s.Sum256(in[:])
+	k.x.fin = true
+}
+
+// XORKeyStream XORs each byte in src with a byte from the keystream and
+// writes the result into dst. It implements cipher.Stream. Dst and src must
+// overlap entirely or not at all.
+func (k *Keystream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("blake2xs: output smaller than input")
+	}
+	var buf [bufferSize]byte
+	for len(src) > 0 {
+		n := len(src)
+		if n > len(buf) {
+			n = len(buf)
+		}
+		if _, err := k.Read(buf[:n]); err != nil {
+			panic("blake2xs: " + err.Error())
+		}
+		for i, v := range buf[:n] {
+			dst[i] = src[i] ^ v
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
@@ -0,0 +1,71 @@
+package blake2xs
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/dchest/blake2s"
+)
+
+var _ cipher.Stream = (*Keystream)(nil)
+
+func TestKeystream(t *testing.T) {
+	c := &Config{Key: []byte("key"), Salt: []byte("salt")}
+	k, err := NewKeystream(c)
+	if err != nil {
+		t.Fatalf("error creating: %s", err)
+	}
+	ks := make([]byte, 3*UnknownSize+100)
+	if n, err := k.Read(ks); n != len(ks) || err != nil {
+		t.Fatalf("error reading: %v (n = %d)", err, n)
+	}
+
+	// The first segment is the XOF output.
+	h, _ := NewXOF(c)
+	seg := make([]byte, UnknownSize)
+	h.Read(seg)
+	if !bytes.Equal(ks[:UnknownSize], seg) {
+		t.Errorf("first segment differs from XOF output")
+	}
+	h0, _ := h.RootDigest()
+
+	// Next segments use the derived root digests.
+	for j := 1; j < 4; j++ {
+		var in [blake2s.Size + 8]byte
+		copy(in[:], h0)
+		binary.LittleEndian.PutUint64(in[blake2s.Size:], uint64(j))
+		x := &XOF{oc: h.oc, size: UnknownSize}
+		x.resetOutput()
+		x.fin = true
+		x.h0 = blake2s.Sum256(in[:])
+		got := ks[j*UnknownSize:]
+		if len(got) > UnknownSize {
+			got = got[:UnknownSize]
+		}
+		want := make([]byte, len(got))
+		io.ReadFull(x, want)
+		if !bytes.Equal(got, want) {
+			t.Errorf("segment %d differs", j)
+		}
+	}
+
+	// XORKeyStream uses the same keystream.
+	k, _ = NewKeystream(c)
+	ct := make([]byte, len(ks))
+	for i, n := 0, 1000; i < len(ct); i += n {
+		if i+n > len(ct) {
+			n = len(ct) - i
+		}
+		k.XORKeyStream(ct[i:i+n], ct[i:i+n])
+	}
+	if !bytes.Equal(ct, ks) {
+		t.Errorf("XORKeyStream doesn't match Read")
+	}
+
+	if _, err := NewKeystream(&Config{Size: 100}); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+}
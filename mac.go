@@ -0,0 +1,75 @@
+package blake2xs
+
+import "crypto/subtle"
+
+// NewMAC returns a new XOF computing a MAC of the given size with key.
+func NewMAC(key []byte, size int) (*XOF, error) {
+	if size == 0 || !validSize(size) {
+		return nil, ErrSize
+	}
+	return NewXOF(&Config{Size: uint16(size), Key: key})
+}
+
+// VerifyMAC reports whether tag is a valid MAC of message with key,
+// computing a MAC of len(tag) bytes. Tags are compared in constant time.
+func VerifyMAC(key, message, tag []byte) bool {
+	if len(tag) == 0 || len(tag) > UnknownSize {
+		return false
+	}
+	mac := make([]byte, len(tag))
+	if err := Sum(mac, message, &Config{Key: key}); err != nil {
+		return false
+	}
+	return ConstantTimeEqual(mac, tag)
+}
+
+// ConstantTimeEqual reports whether a and b are equal. The time taken is
+// independent of the contents of a and b, but not of their lengths, so both
+// should have the same expected length, such as the length of a MAC tag.
+// If lengths differ, it returns false.
+func ConstantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// Verifier incrementally computes the XOF of data written to it and checks
+// it against the expected tag.
+type Verifier struct {
+	x   *XOF
+	tag []byte
+}
+
+// NewVerifier returns a new Verifier, which checks that the XOF with config
+// c and output size len(tag) of the written data is equal to tag. Config may
+// be nil. If config size is not zero, it must be equal to len(tag).
+func NewVerifier(tag []byte, c *Config) (*Verifier, error) {
+	if len(tag) == 0 || !validSize(len(tag)) {
+		return nil, ErrSize
+	}
+	var cc Config
+	if c != nil {
+		if c.Size != 0 && int(c.Size) != len(tag) {
+			return nil, ErrSizeMismatch
+		}
+		cc = *c
+	}
+	cc.Size = uint16(len(tag))
+	x, err := NewXOF(&cc)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{x: x, tag: append([]byte(nil), tag...)}, nil
+}
+
+// Write writes data into the verifier.
+func (v *Verifier) Write(p []byte) (nn int, err error) { return v.x.Write(p) }
+
+// Verify reports whether the XOF of data written so far is equal to the
+// expected tag. Tags are compared in constant time. No data can be written
+// after calling Verify.
+func (v *Verifier) Verify() bool {
+	out := make([]byte, len(v.tag))
+	if _, err := v.x.ReadAt(out, 0); err != nil {
+		return false
+	}
+	return ConstantTimeEqual(out, v.tag)
+}
@@ -0,0 +1,104 @@
+package blake2xs
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMAC(t *testing.T) {
+	key := []byte("mac key")
+	msg := []byte("message")
+	for _, size := range []int{1, 16, 32, 64} {
+		m, err := NewMAC(key, size)
+		if err != nil {
+			t.Fatalf("size %d: error creating: %s", size, err)
+		}
+		m.Write(msg)
+		tag := make([]byte, size)
+		if _, err := io.ReadFull(m, tag); err != nil {
+			t.Fatalf("size %d: error reading: %s", size, err)
+		}
+		if !VerifyMAC(key, msg, tag) {
+			t.Errorf("size %d: valid tag rejected", size)
+		}
+		tag[len(tag)-1] ^= 1
+		if VerifyMAC(key, msg, tag) {
+			t.Errorf("size %d: invalid tag accepted", size)
+		}
+		tag[len(tag)-1] ^= 1
+		if VerifyMAC([]byte("other key"), msg, tag) {
+			t.Errorf("size %d: tag accepted with wrong key", size)
+		}
+		if VerifyMAC(key, []byte("other message"), tag) {
+			t.Errorf("size %d: tag accepted for wrong message", size)
+		}
+	}
+	if VerifyMAC(key, msg, nil) {
+		t.Errorf("empty tag accepted")
+	}
+	for _, size := range []int{0, -1, UnknownSize + 1} {
+		if _, err := NewMAC(key, size); err != ErrSize {
+			t.Errorf("size %d: expected ErrSize, got %v", size, err)
+		}
+	}
+}
+
+func TestConstantTimeEqual(t *testing.T) {
+	for i, v := range []struct {
+		a, b string
+		eq   bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"abc", "ab", false},
+		{"", "a", false},
+	} {
+		if got := ConstantTimeEqual([]byte(v.a), []byte(v.b)); got != v.eq {
+			t.Errorf("%d: expected %v, got %v", i, v.eq, got)
+		}
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	c := &Config{Key: []byte("key")}
+	data := make([]byte, 3000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	tag := make([]byte, 32)
+	Sum(tag, data, c)
+
+	newVerifier := func() *Verifier {
+		v, err := NewVerifier(tag, c)
+		if err != nil {
+			t.Fatalf("error creating: %s", err)
+		}
+		return v
+	}
+	v := newVerifier()
+	for i := 0; i < len(data); i += 1000 {
+		v.Write(data[i : i+1000])
+	}
+	if !v.Verify() {
+		t.Errorf("valid data rejected")
+	}
+	if !v.Verify() {
+		t.Errorf("repeated Verify failed")
+	}
+	for _, i := range []int{0, 1, 1500, len(data) - 1} {
+		data[i] ^= 1
+		v := newVerifier()
+		v.Write(data)
+		if v.Verify() {
+			t.Errorf("tampered data at %d accepted", i)
+		}
+		data[i] ^= 1
+	}
+	if _, err := NewVerifier(nil, c); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+	if _, err := NewVerifier(tag, &Config{Size: 16}); err != ErrSizeMismatch {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}
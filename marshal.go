@@ -0,0 +1,263 @@
+package blake2xs
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/dchest/blake2s"
+)
+
+const (
+	magic          = "b2xs"
+	marshalVersion = 3
+	headerSize     = len(magic) + 4
+	finalizedSize  = headerSize + blake2s.Size + 8 + 1 + 2 + blake2s.Size
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// If the root hash is not finalized yet, its state is marshaled, which
+// requires it to implement encoding.BinaryMarshaler, along with the number
+// of bytes written and whether Write was called.
+func (x *XOF) MarshalBinary() ([]byte, error) {
+	if x.err != nil {
+		return nil, x.err
+	}
+	b := make([]byte, headerSize, finalizedSize)
+	copy(b, magic)
+	b[len(magic)] = marshalVersion
+	binary.BigEndian.PutUint16(b[len(magic)+1:], uint16(x.size))
+	if !x.fin {
+		m, ok := x.rh.(encoding.BinaryMarshaler)
+		if !ok {
+			return nil, ErrNoMarshal
+		}
+		rs, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		var tmp [8]byte
+		binary.BigEndian.PutUint64(tmp[:], uint64(x.n))
+		b = append(b, tmp[:]...)
+		var wrote byte
+		if x.wrote {
+			wrote = 1
+		}
+		b = append(b, wrote)
+		return append(b, rs...), nil
+	}
+	b[headerSize-1] = 1 // finalized
+	b = append(b, x.h0[:]...)
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], uint64(x.size)<<32+uint64(x.next))
+	b = append(b, tmp[:]...)
+	b = append(b, byte(x.px))
+	binary.BigEndian.PutUint16(tmp[:], uint16(x.left))
+	b = append(b, tmp[:2]...)
+	b = append(b, x.x[:]...)
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+//
+// The state must be unmarshaled into an XOF created with the same config.
+func (x *XOF) UnmarshalBinary(b []byte) error {
+	if len(b) < headerSize || string(b[:len(magic)]) != magic {
+		return ErrInvalidState
+	}
+	if b[len(magic)] != marshalVersion {
+		return ErrStateVersion
+	}
+	if int(binary.BigEndian.Uint16(b[len(magic)+1:])) != x.size {
+		return ErrStateSize
+	}
+	switch b[headerSize-1] {
+	case 0:
+		u, ok := x.rh.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return ErrNoMarshal
+		}
+		b = b[headerSize:]
+		if len(b) < 9 || binary.BigEndian.Uint64(b) > 1<<63-1 || b[8] > 1 {
+			return ErrInvalidState
+		}
+		n := int64(binary.BigEndian.Uint64(b))
+		wrote := b[8] == 1
+		b = b[9:]
+		if err := u.UnmarshalBinary(b); err != nil {
+			return err
+		}
+		x.n = n
+		x.wrote = wrote
+		x.stale = false
+		x.resetOutput()
+	case 1:
+		if len(b) != finalizedSize {
+			return ErrInvalidState
+		}
+		b = b[headerSize:]
+		h0 := b[:blake2s.Size]
+		b = b[blake2s.Size:]
+		off := binary.BigEndian.Uint64(b)
+		px := int(b[8])
+		left := int(binary.BigEndian.Uint16(b[9:]))
+		base := uint64(x.size) << 32
+		if px > blake2s.Size || left > x.size || off < base || off-base > UnknownSize/blake2s.Size+1 {
+			return ErrInvalidState
+		}
+		next := int(off - base)
+		if left == 0 {
+			// Output is exhausted, block index doesn't matter.
+			px = blake2s.Size
+		} else if next*blake2s.Size != x.size-left+blake2s.Size-px {
+			// Buffer position must match output position.
+			return ErrInvalidState
+		}
+		copy(x.h0[:], h0)
+		x.fin = true
+		x.stale = true // root hash doesn't have the input
+		x.next = next
+		x.px = px
+		x.left = left
+		copy(x.x[:], b[11:])
+	default:
+		return ErrInvalidState
+	}
+	return nil
+}
+
+type jsonTree struct {
+	Fanout        uint8  `json:"fanout"`
+	MaxDepth      uint8  `json:"maxDepth"`
+	LeafSize      uint32 `json:"leafSize"`
+	NodeOffset    uint64 `json:"nodeOffset"`
+	NodeDepth     uint8  `json:"nodeDepth"`
+	InnerHashSize uint8  `json:"innerHashSize"`
+	IsLastNode    bool   `json:"isLastNode"`
+}
+
+type jsonConfig struct {
+	Size             uint16    `json:"size,omitempty"`
+	Key              string    `json:"key,omitempty"`
+	Salt             string    `json:"salt,omitempty"`
+	Person           string    `json:"person,omitempty"`
+	Tree             *jsonTree `json:"tree,omitempty"`
+	MinInput         int       `json:"minInput,omitempty"`
+	Strict           bool      `json:"strict,omitempty"`
+	NoShortLastBlock bool      `json:"noShortLastBlock,omitempty"`
+	BufferSize       int       `json:"bufferSize,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Byte slices are encoded as
+// hex strings, and empty fields are omitted. Backend, Tap and
+// BlockPerson are not encoded.
+func (c Config) MarshalJSON() ([]byte, error) {
+	jc := jsonConfig{
+		Size:             c.Size,
+		Key:              hex.EncodeToString(c.Key),
+		Salt:             hex.EncodeToString(c.Salt),
+		Person:           hex.EncodeToString(c.Person),
+		MinInput:         c.MinInput,
+		Strict:           c.Strict,
+		NoShortLastBlock: c.NoShortLastBlock,
+		BufferSize:       c.BufferSize,
+	}
+	if t := c.Tree; t != nil {
+		jc.Tree = &jsonTree{
+			Fanout:        t.Fanout,
+			MaxDepth:      t.MaxDepth,
+			LeafSize:      t.LeafSize,
+			NodeOffset:    t.NodeOffset,
+			NodeDepth:     t.NodeDepth,
+			InnerHashSize: t.InnerHashSize,
+			IsLastNode:    t.IsLastNode,
+		}
+	}
+	return json.Marshal(&jc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Backend, Tap and
+// BlockPerson are not changed.
+func (c *Config) UnmarshalJSON(b []byte) error {
+	var jc jsonConfig
+	if err := json.Unmarshal(b, &jc); err != nil {
+		return err
+	}
+	var nc Config
+	var err error
+	nc.Size = jc.Size
+	nc.MinInput = jc.MinInput
+	nc.Strict = jc.Strict
+	nc.NoShortLastBlock = jc.NoShortLastBlock
+	nc.BufferSize = jc.BufferSize
+	if nc.Key, err = decodeHex(jc.Key); err != nil {
+		return err
+	}
+	if nc.Salt, err = decodeHex(jc.Salt); err != nil {
+		return err
+	}
+	if nc.Person, err = decodeHex(jc.Person); err != nil {
+		return err
+	}
+	if t := jc.Tree; t != nil {
+		nc.Tree = &blake2s.Tree{
+			Fanout:        t.Fanout,
+			MaxDepth:      t.MaxDepth,
+			LeafSize:      t.LeafSize,
+			NodeOffset:    t.NodeOffset,
+			NodeDepth:     t.NodeDepth,
+			InnerHashSize: t.InnerHashSize,
+			IsLastNode:    t.IsLastNode,
+		}
+	}
+	nc.Backend = c.Backend
+	nc.Tap = c.Tap
+	nc.BlockPerson = c.BlockPerson
+	*c = nc
+	return nil
+}
+
+// decodeHex decodes hex string s, returning nil for empty string.
+func decodeHex(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// cursorSize is the size of marshaled cursor: output size and position.
+const cursorSize = 4
+
+// MarshalCursor returns the current output position of the finalized XOF.
+// Unlike MarshalBinary, it doesn't include the root digest, so the cursor
+// can only be restored into XOF with the same config and input.
+func (x *XOF) MarshalCursor() ([]byte, error) {
+	if !x.fin {
+		return nil, ErrNotFinalized
+	}
+	b := make([]byte, cursorSize)
+	binary.BigEndian.PutUint16(b, uint16(x.size))
+	binary.BigEndian.PutUint16(b[2:], uint16(x.size-x.left))
+	return b, nil
+}
+
+// RestoreCursor sets the output position of the finalized XOF to the one
+// returned by MarshalCursor.
+func (x *XOF) RestoreCursor(b []byte) error {
+	if !x.fin {
+		return ErrNotFinalized
+	}
+	if len(b) != cursorSize {
+		return ErrInvalidState
+	}
+	if int(binary.BigEndian.Uint16(b)) != x.size {
+		return ErrStateSize
+	}
+	pos := int(binary.BigEndian.Uint16(b[2:]))
+	if pos > x.size {
+		return ErrInvalidState
+	}
+	return x.setPos(int64(pos))
+}
@@ -0,0 +1,229 @@
+package blake2xs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/dchest/blake2s"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*XOF)(nil)
+	_ encoding.BinaryUnmarshaler = (*XOF)(nil)
+)
+
+func TestMarshal(t *testing.T) {
+	in := make([]byte, 200)
+	for i := range in {
+		in[i] = byte(i)
+	}
+	for _, size := range []uint16{1, 33, 100, 1024} {
+		c := &Config{Size: size, Key: []byte("key")}
+		h, _ := NewXOF(c)
+		h.Write(in)
+		want := make([]byte, size)
+		h.Read(want)
+
+		// Before finalization.
+		for _, split := range []int{0, 1, 64, 65, 199} {
+			h, _ := NewXOF(c)
+			h.Write(in[:split])
+			state, err := h.MarshalBinary()
+			if err != nil {
+				t.Fatalf("size %d, split %d: error marshaling: %s", size, split, err)
+			}
+			h2, _ := NewXOF(c)
+			if err := h2.UnmarshalBinary(state); err != nil {
+				t.Fatalf("size %d, split %d: error unmarshaling: %s", size, split, err)
+			}
+			h2.Write(in[split:])
+			got := make([]byte, size)
+			h2.Read(got)
+			if !bytes.Equal(got, want) {
+				t.Errorf("size %d, split %d: output mismatch", size, split)
+			}
+		}
+
+		// After finalization.
+		for _, split := range []int{0, 1, 32, 33, int(size)} {
+			if split > int(size) {
+				continue
+			}
+			h, _ := NewXOF(c)
+			h.Write(in)
+			h.Read(make([]byte, split))
+			state, err := h.MarshalBinary()
+			if err != nil {
+				t.Fatalf("size %d, split %d: error marshaling: %s", size, split, err)
+			}
+			h2, _ := NewXOF(c)
+			if err := h2.UnmarshalBinary(state); err != nil {
+				t.Fatalf("size %d, split %d: error unmarshaling: %s", size, split, err)
+			}
+			if _, err := h2.Write([]byte{1}); err != ErrWriteAfterRead {
+				t.Errorf("size %d, split %d: expected error writing after unmarshaling finalized state", size, split)
+			}
+			got := make([]byte, int(size)-split)
+			n, _ := h2.Read(got)
+			if n != len(got) || !bytes.Equal(got, want[split:]) {
+				t.Errorf("size %d, split %d: output mismatch", size, split)
+			}
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	h, _ := NewXOF(&Config{Size: 64})
+	h.Read(make([]byte, 10))
+	state, _ := h.MarshalBinary()
+
+	h2, _ := NewXOF(&Config{Size: 65})
+	if err := h2.UnmarshalBinary(state); err != ErrStateSize {
+		t.Errorf("expected error for different size")
+	}
+	h3, _ := NewXOF(&Config{Size: 64})
+	for _, b := range [][]byte{nil, state[:5], state[:len(state)-1], append([]byte("xxxx"), state[4:]...)} {
+		if err := h3.UnmarshalBinary(b); err != ErrInvalidState {
+			t.Errorf("expected error for invalid state %x", b)
+		}
+	}
+
+	// Inconsistent block index, buffer position and bytes left.
+	for _, v := range []struct{ next, px, left int }{
+		{1, 32, 64},
+		{0, 10, 54},
+		{2, 10, 54},
+		{2, 32, 54},
+		{3, 32, 1},
+	} {
+		bad := append([]byte(nil), state...)
+		b := bad[headerSize+blake2s.Size:]
+		binary.BigEndian.PutUint64(b, 64<<32+uint64(v.next))
+		b[8] = byte(v.px)
+		binary.BigEndian.PutUint16(b[9:], uint16(v.left))
+		if err := h3.UnmarshalBinary(bad); err != ErrInvalidState {
+			t.Errorf("expected ErrInvalidState for next %d, px %d, left %d, got %v", v.next, v.px, v.left, err)
+		}
+	}
+	bad := append([]byte(nil), state...)
+	bad[len(magic)] = marshalVersion + 1
+	if err := h3.UnmarshalBinary(bad); err != ErrStateVersion {
+		t.Errorf("expected error for unsupported version")
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	for i, c := range []Config{
+		{},
+		{Size: 64, Key: []byte("key"), Salt: []byte("salt"), Person: []byte("person")},
+		{MinInput: 16, Strict: true, NoShortLastBlock: true, BufferSize: 100},
+		{Size: 1, Tree: &blake2s.Tree{Fanout: 2, MaxDepth: 3, LeafSize: 4096, NodeOffset: 5, NodeDepth: 1, InnerHashSize: 32, IsLastNode: true}},
+	} {
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatalf("%d: error marshaling: %s", i, err)
+		}
+		var c2 Config
+		if err := json.Unmarshal(b, &c2); err != nil {
+			t.Fatalf("%d: error unmarshaling %s: %s", i, b, err)
+		}
+		if !reflect.DeepEqual(c, c2) {
+			t.Errorf("%d: expected %+v, got %+v", i, c, c2)
+		}
+	}
+
+	b, _ := json.Marshal(&Config{Size: 32, Key: []byte{0xab, 0xcd}})
+	if want := `{"size":32,"key":"abcd"}`; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+	var c Config
+	if err := json.Unmarshal([]byte(`{"key":"xyz"}`), &c); err == nil {
+		t.Errorf("expected error for invalid hex")
+	}
+}
+
+func TestMarshalMinInput(t *testing.T) {
+	c := &Config{MinInput: 10}
+	h, _ := NewXOF(c)
+	h.Write(make([]byte, 9))
+	state, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error marshaling: %s", err)
+	}
+	h2, _ := NewXOF(c)
+	if err := h2.UnmarshalBinary(state); err != nil {
+		t.Fatalf("error unmarshaling: %s", err)
+	}
+	if err := h2.Finalize(); err != ErrShortInput {
+		t.Errorf("expected ErrShortInput, got %v", err)
+	}
+	h2.UnmarshalBinary(state)
+	h2.Write([]byte{0})
+	if err := h2.Finalize(); err != nil {
+		t.Errorf("error finalizing: %s", err)
+	}
+	if _, err := h.Read(nil); err != ErrShortInput {
+		t.Fatalf("expected ErrShortInput, got %v", err)
+	}
+	if _, err := h.MarshalBinary(); err != ErrShortInput {
+		t.Errorf("expected ErrShortInput when marshaling failed state, got %v", err)
+	}
+
+	// Version 1 states without input length are not accepted.
+	h, _ = NewXOF(nil)
+	state, _ = h.MarshalBinary()
+	v1 := append(state[:headerSize:headerSize], state[headerSize+8:]...)
+	v1[len(magic)] = 1
+	h2, _ = NewXOF(c)
+	if err := h2.UnmarshalBinary(v1); err != ErrStateVersion {
+		t.Errorf("expected ErrStateVersion for version 1 state, got %v", err)
+	}
+}
+
+func TestMarshalCursor(t *testing.T) {
+	c := &Config{Size: 1000, Key: []byte("key")}
+	want := make([]byte, 1000)
+	Sum(want, []byte("abc"), c)
+	for _, pos := range []int{0, 1, 32, 33, 999, 1000} {
+		h, _ := NewXOF(c)
+		h.Write([]byte("abc"))
+		h.Read(make([]byte, pos))
+		cur, err := h.MarshalCursor()
+		if err != nil {
+			t.Fatalf("%d: error marshaling: %s", pos, err)
+		}
+		h2, _ := NewXOF(c)
+		h2.Write([]byte("abc"))
+		h2.Finalize()
+		if err := h2.RestoreCursor(cur); err != nil {
+			t.Fatalf("%d: error restoring: %s", pos, err)
+		}
+		got := make([]byte, 1000-pos)
+		if _, err := io.ReadFull(h2, got); err != nil || !bytes.Equal(got, want[pos:]) {
+			t.Errorf("%d: wrong output after restoring (err = %v)", pos, err)
+		}
+	}
+
+	h, _ := NewXOF(c)
+	if _, err := h.MarshalCursor(); err != ErrNotFinalized {
+		t.Errorf("expected ErrNotFinalized, got %v", err)
+	}
+	if err := h.RestoreCursor([]byte{0, 0, 0, 0}); err != ErrNotFinalized {
+		t.Errorf("expected ErrNotFinalized, got %v", err)
+	}
+	h.Finalize()
+	if err := h.RestoreCursor([]byte{0, 1, 0, 0}); err != ErrStateSize {
+		t.Errorf("expected ErrStateSize, got %v", err)
+	}
+	if err := h.RestoreCursor([]byte{0x03, 0xe8, 0x03, 0xe9}); err != ErrInvalidState {
+		t.Errorf("expected ErrInvalidState for position past end, got %v", err)
+	}
+	if err := h.RestoreCursor([]byte{0}); err != ErrInvalidState {
+		t.Errorf("expected ErrInvalidState for short cursor, got %v", err)
+	}
+}
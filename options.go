@@ -0,0 +1,73 @@
+package blake2xs
+
+import (
+	"io"
+
+	"github.com/dchest/blake2s"
+)
+
+// Option sets a config parameter for NewXOFWith.
+type Option func(*Config)
+
+// WithSize sets output size.
+func WithSize(size uint16) Option { return func(c *Config) { c.Size = size } }
+
+// WithKey sets key.
+func WithKey(key []byte) Option { return func(c *Config) { c.Key = key } }
+
+// WithSalt sets salt.
+func WithSalt(salt []byte) Option { return func(c *Config) { c.Salt = salt } }
+
+// WithPerson sets personalization.
+func WithPerson(person []byte) Option { return func(c *Config) { c.Person = person } }
+
+// WithSalt8 sets salt from an 8-byte array.
+func WithSalt8(salt [8]byte) Option {
+	return func(c *Config) { c.Salt = append([]byte(nil), salt[:]...) }
+}
+
+// WithPerson8 sets personalization from an 8-byte array.
+func WithPerson8(person [8]byte) Option {
+	return func(c *Config) { c.Person = append([]byte(nil), person[:]...) }
+}
+
+// WithTree sets parameters for tree hashing.
+func WithTree(tree *blake2s.Tree) Option { return func(c *Config) { c.Tree = tree } }
+
+// WithMinInput sets the minimum number of input bytes.
+func WithMinInput(n int) Option { return func(c *Config) { c.MinInput = n } }
+
+// WithStrict enables strict mode, see Config.Strict.
+func WithStrict() Option { return func(c *Config) { c.Strict = true } }
+
+// WithTap sets the writer receiving a copy of input.
+func WithTap(w io.Writer) Option { return func(c *Config) { c.Tap = w } }
+
+// WithBlockPerson sets the function returning personalization for output
+// blocks. Output with it is not standard BLAKE2Xs.
+func WithBlockPerson(f func(i uint64) []byte) Option {
+	return func(c *Config) { c.BlockPerson = f }
+}
+
+// WithNoShortLastBlock makes the last output block a truncated full-size
+// digest. Output with it is not standard BLAKE2Xs.
+func WithNoShortLastBlock() Option {
+	return func(c *Config) { c.NoShortLastBlock = true }
+}
+
+// WithBufferSize sets the size of buffer used for streaming, which is
+// rounded up to a multiple of BlockSize.
+func WithBufferSize(n int) Option { return func(c *Config) { c.BufferSize = n } }
+
+// WithBackend sets the function used to create BLAKE2s instances.
+func WithBackend(b Backend) Option { return func(c *Config) { c.Backend = b } }
+
+// NewXOFWith returns a new extended output function configured with the
+// given options. Without options, it's the same as NewXOF(nil).
+func NewXOFWith(opts ...Option) (*XOF, error) {
+	var c Config
+	for _, o := range opts {
+		o(&c)
+	}
+	return NewXOF(&c)
+}
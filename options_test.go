@@ -0,0 +1,252 @@
+package blake2xs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/dchest/blake2s"
+)
+
+func TestNewXOFWith(t *testing.T) {
+	tree := &blake2s.Tree{Fanout: 1, MaxDepth: 1, NodeOffset: 3}
+	h1, err := NewXOFWith(
+		WithSize(100),
+		WithKey([]byte("key")),
+		WithSalt([]byte("salt")),
+		WithPerson([]byte("person")),
+		WithTree(tree),
+	)
+	if err != nil {
+		t.Fatalf("error creating: %s", err)
+	}
+	h2, _ := NewXOF(&Config{
+		Size:   100,
+		Key:    []byte("key"),
+		Salt:   []byte("salt"),
+		Person: []byte("person"),
+		Tree:   tree,
+	})
+	out1, out2 := make([]byte, 100), make([]byte, 100)
+	h1.Read(out1)
+	h2.Read(out2)
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("NewXOFWith output differs from NewXOF")
+	}
+
+	h1, _ = NewXOFWith()
+	h2, _ = NewXOF(nil)
+	if h1.Size() != h2.Size() {
+		t.Errorf("expected size %d, got %d", h2.Size(), h1.Size())
+	}
+
+	if _, err := NewXOFWith(WithSalt(make([]byte, 9))); err != ErrSaltSize {
+		t.Errorf("expected ErrSaltSize, got %v", err)
+	}
+}
+
+func TestWithMinInput(t *testing.T) {
+	for _, n := range []int{0, 1, 5, 100} {
+		h, err := NewXOFWith(WithKey([]byte("key")), WithMinInput(5))
+		if err != nil {
+			t.Fatalf("error creating: %s", err)
+		}
+		h.Write(make([]byte, n))
+		err = h.Finalize()
+		if n < 5 && err != ErrShortInput {
+			t.Errorf("%d: expected ErrShortInput, got %v", n, err)
+		}
+		if n >= 5 && err != nil {
+			t.Errorf("%d: error: %s", n, err)
+		}
+		if _, rerr := h.Read(make([]byte, 1)); rerr != err {
+			t.Errorf("%d: Read returned %v, Finalize %v", n, rerr, err)
+		}
+	}
+	h, _ := NewXOFWith(WithMinInput(5))
+	h.Write(make([]byte, 4))
+	if _, err := h.Read(make([]byte, 1)); err != ErrShortInput {
+		t.Errorf("expected ErrShortInput on first Read, got %v", err)
+	}
+	h.Reset()
+	h.Write(make([]byte, 5))
+	if _, err := h.Read(make([]byte, 1)); err != nil {
+		t.Errorf("after Reset: error: %s", err)
+	}
+	if _, err := NewXOFWith(WithMinInput(-1)); err != ErrNegativeCount {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	// Reading before writing.
+	for i, read := range []func(x *XOF) error{
+		func(x *XOF) error { _, err := x.Read(make([]byte, 1)); return err },
+		func(x *XOF) error { _, err := x.ReadAt(make([]byte, 1), 0); return err },
+		func(x *XOF) error { _, err := x.Seek(1, io.SeekStart); return err },
+		func(x *XOF) error { return x.Finalize() },
+	} {
+		h, _ := NewXOFWith(WithStrict())
+		if err := read(h); err != ErrNoInput {
+			t.Errorf("%d: expected ErrNoInput, got %v", i, err)
+		}
+		if _, err := h.Write([]byte("abc")); err != ErrWriteAfterRead {
+			t.Errorf("%d: expected ErrWriteAfterRead, got %v", i, err)
+		}
+	}
+
+	// Empty write is enough.
+	h, _ := NewXOFWith(WithStrict())
+	h.Write(nil)
+	if _, err := h.Read(make([]byte, 1)); err != nil {
+		t.Errorf("error: %s", err)
+	}
+	// Writing after reading.
+	if _, err := h.Write([]byte("abc")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	// Reset returns to the initial state.
+	h.Reset()
+	if _, err := h.Read(make([]byte, 1)); err != ErrNoInput {
+		t.Errorf("after Reset: expected ErrNoInput, got %v", err)
+	}
+
+	// Marshaling keeps whether Write was called.
+	for _, write := range []bool{false, true} {
+		h, _ := NewXOFWith(WithStrict())
+		if write {
+			h.Write(nil)
+		}
+		state, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("error marshaling: %s", err)
+		}
+		h2, _ := NewXOFWith(WithStrict())
+		if err := h2.UnmarshalBinary(state); err != nil {
+			t.Fatalf("error unmarshaling: %s", err)
+		}
+		if err := h2.Finalize(); write && err != nil || !write && err != ErrNoInput {
+			t.Errorf("wrote %v: unexpected error after unmarshaling: %v", write, err)
+		}
+	}
+
+	// Not strict by default.
+	h, _ = NewXOFWith()
+	if _, err := h.Read(make([]byte, 1)); err != nil {
+		t.Errorf("error: %s", err)
+	}
+}
+
+func TestWithSalt8Person8(t *testing.T) {
+	h1, _ := NewXOFWith(
+		WithSalt8([8]byte{'s', 'a', 'l', 't'}),
+		WithPerson8([8]byte{'p', 'e', 'r', 's', 'o', 'n', 'a', 'l'}),
+	)
+	h2, _ := NewXOFWith(WithSalt([]byte("salt")), WithPerson([]byte("personal")))
+	out1, out2 := make([]byte, 64), make([]byte, 64)
+	h1.Read(out1)
+	h2.Read(out2)
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("output with array parameters differs")
+	}
+}
+
+func TestWithTap(t *testing.T) {
+	var tap bytes.Buffer
+	h, _ := NewXOFWith(WithSize(64), WithTap(&tap))
+	h.Write([]byte("abc"))
+	h.WriteString("def")
+	h.ReadFrom(bytes.NewReader([]byte("ghi")))
+	if tap.String() != "abcdefghi" {
+		t.Errorf("expected tap to receive %q, got %q", "abcdefghi", tap.String())
+	}
+	got := make([]byte, 64)
+	h.Read(got)
+	want := make([]byte, 64)
+	Sum(want, []byte("abcdefghi"), nil)
+	if !bytes.Equal(got, want) {
+		t.Errorf("tap changed output")
+	}
+	if _, err := h.Write([]byte("x")); err != ErrWriteAfterRead {
+		t.Errorf("expected ErrWriteAfterRead, got %v", err)
+	}
+	if tap.String() != "abcdefghi" {
+		t.Errorf("tap received data after reading")
+	}
+
+	h, _ = NewXOFWith(WithTap(&errWriter{n: 2}))
+	if n, err := h.Write([]byte("abc")); n != 3 || err == nil {
+		t.Errorf("expected 3 and error, got %d, %v", n, err)
+	}
+	if _, err := h.WriteString("abc"); err == nil {
+		t.Errorf("WriteString: expected error")
+	}
+}
+
+func TestWithBlockPerson(t *testing.T) {
+	person := []byte("person")
+	std, _ := NewXOFWith(WithSize(64), WithPerson(person))
+	want := make([]byte, 64)
+	std.Read(want)
+
+	h, _ := NewXOFWith(WithSize(64), WithPerson(person), WithBlockPerson(func(i uint64) []byte {
+		if i == 0 {
+			return person
+		}
+		return []byte("other")
+	}))
+	got := make([]byte, 64)
+	if _, err := h.Read(got); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !bytes.Equal(got[:32], want[:32]) {
+		t.Errorf("block with the same personalization differs")
+	}
+	if bytes.Equal(got[32:], want[32:]) {
+		t.Errorf("block with different personalization is the same")
+	}
+
+	h, _ = NewXOFWith(WithBlockPerson(func(i uint64) []byte { return make([]byte, 9) }))
+	if _, err := h.Read(make([]byte, 1)); err == nil {
+		t.Errorf("expected error for too long personalization")
+	}
+}
+
+// chunkWriter records lengths of writes.
+type chunkWriter struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestWithBufferSize(t *testing.T) {
+	std, _ := NewXOFWith(WithSize(1000))
+	std.Write([]byte("abc"))
+	var want bytes.Buffer
+	std.WriteTo(&want)
+
+	for _, v := range []struct{ n, chunk int }{{1, 32}, {33, 64}, {64, 64}, {5000, 1000}} {
+		h, err := NewXOFWith(WithSize(1000), WithBufferSize(v.n))
+		if err != nil {
+			t.Fatalf("%d: error: %s", v.n, err)
+		}
+		h.ReadFrom(bytes.NewReader([]byte("abc")))
+		var w chunkWriter
+		if _, err := h.WriteTo(&w); err != nil {
+			t.Fatalf("%d: error writing: %s", v.n, err)
+		}
+		if !bytes.Equal(w.Bytes(), want.Bytes()) {
+			t.Errorf("%d: output differs", v.n)
+		}
+		if w.chunks[0] != v.chunk {
+			t.Errorf("%d: expected chunk of %d bytes, got %d", v.n, v.chunk, w.chunks[0])
+		}
+	}
+	if _, err := NewXOFWith(WithBufferSize(-1)); err != ErrBufferSize {
+		t.Errorf("expected ErrBufferSize, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package blake2xs
+
+import (
+	"encoding/binary"
+	"math/rand"
+)
+
+type source struct {
+	k   *Keystream
+	buf [8]byte
+}
+
+// NewSource returns a deterministic math/rand source, which produces
+// little-endian 64-bit words from the keystream of NewKeystream with
+// config c, so it never runs out. Config size is ignored. Config may be nil.
+//
+// Seed method of the returned source does nothing: use key or salt
+// to get different sequences.
+func NewSource(c *Config) (rand.Source64, error) {
+	var cc Config
+	if c != nil {
+		cc = *c
+	}
+	cc.Size = 0
+	k, err := NewKeystream(&cc)
+	if err != nil {
+		return nil, err
+	}
+	return &source{k: k}, nil
+}
+
+func (s *source) Uint64() uint64 {
+	if _, err := s.k.Read(s.buf[:]); err != nil {
+		panic(err) // only if the backend fails
+	}
+	return binary.LittleEndian.Uint64(s.buf[:])
+}
+
+func (s *source) Int63() int64 { return int64(s.Uint64() & (1<<63 - 1)) }
+
+func (s *source) Seed(seed int64) {}
+
+// Shuffle pseudo-randomizes the order of n elements with Fisher-Yates
+// shuffle, using the keystream of NewKeystream with config c as a source of
+// randomness. Config size is ignored. Config may be nil. Swap swaps the
+// elements with indexes i and j.
+//
+// For each i from n-1 down to 1, Shuffle reads 8-byte little-endian words v
+// from the keystream until v >= 2^64 mod (i+1), which avoids modulo bias,
+// and then calls swap(i, v mod (i+1)).
+func Shuffle(c *Config, n int, swap func(i, j int)) error {
+	if n < 0 {
+		return ErrNegativeCount
+	}
+	var cc Config
+	if c != nil {
+		cc = *c
+	}
+	cc.Size = 0
+	k, err := NewKeystream(&cc)
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	for i := n - 1; i > 0; i-- {
+		m := uint64(i + 1)
+		min := -m % m // 2^64 mod m
+		for {
+			if _, err := k.Read(buf[:]); err != nil {
+				return err
+			}
+			if v := binary.LittleEndian.Uint64(buf[:]); v >= min {
+				swap(i, int(v%m))
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package blake2xs
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	c := &Config{Key: []byte("key"), Salt: []byte("salt")}
+	s, err := NewSource(c)
+	if err != nil {
+		t.Fatalf("error creating source: %s", err)
+	}
+	h, _ := NewXOF(c)
+	b := make([]byte, 8)
+	for i := 0; i < 10; i++ {
+		h.Read(b)
+		if v := s.Uint64(); v != binary.LittleEndian.Uint64(b) {
+			t.Fatalf("%d: wrong value %x", i, v)
+		}
+	}
+
+	// Same config produces the same sequence.
+	r1, r2 := rand.New(mustSource(t, c)), rand.New(mustSource(t, c))
+	for i := 0; i < 100; i++ {
+		if a, b := r1.Intn(1000), r2.Intn(1000); a != b {
+			t.Fatalf("%d: different values: %d and %d", i, a, b)
+		}
+	}
+	r3 := rand.New(mustSource(t, &Config{Key: []byte("other key")}))
+	same := true
+	for i := 0; i < 10; i++ {
+		if r1.Int63() != r3.Int63() {
+			same = false
+		}
+	}
+	if same {
+		t.Errorf("different keys produced the same sequence")
+	}
+	if v := r1.Int63(); v < 0 {
+		t.Errorf("negative Int63: %d", v)
+	}
+}
+
+func mustSource(t *testing.T, c *Config) rand.Source64 {
+	s, err := NewSource(c)
+	if err != nil {
+		t.Fatalf("error creating source: %s", err)
+	}
+	return s
+}
+
+func TestSourceUnbounded(t *testing.T) {
+	c := &Config{Key: []byte("key")}
+	k, _ := NewKeystream(c)
+	r := rand.New(mustSource(t, c))
+	b := make([]byte, 8)
+	for i := 0; i < 3*UnknownSize/8; i++ {
+		k.Read(b)
+		if v := r.Uint64(); v != binary.LittleEndian.Uint64(b) {
+			t.Fatalf("%d: wrong value %x", i, v)
+		}
+	}
+	if p := r.Perm(10000); len(p) != 10000 {
+		t.Errorf("wrong Perm length %d", len(p))
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	c := &Config{Key: []byte("key")}
+	shuffle := func(c *Config, n int) []int {
+		a := make([]int, n)
+		for i := range a {
+			a[i] = i
+		}
+		if err := Shuffle(c, n, func(i, j int) { a[i], a[j] = a[j], a[i] }); err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		return a
+	}
+	for _, n := range []int{0, 1, 2, 10, 100000} {
+		a, b := shuffle(c, n), shuffle(c, n)
+		seen := make([]bool, n)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("%d: same config gives different shuffles", n)
+			}
+			if seen[a[i]] {
+				t.Fatalf("%d: duplicate element %d", n, a[i])
+			}
+			seen[a[i]] = true
+		}
+	}
+
+	// Check the algorithm with a small n.
+	k, _ := NewKeystream(c)
+	want := []int{0, 1, 2, 3, 4}
+	buf := make([]byte, 8)
+	for i := len(want) - 1; i > 0; i-- {
+		k.Read(buf)
+		j := int(binary.LittleEndian.Uint64(buf) % uint64(i+1))
+		want[i], want[j] = want[j], want[i]
+	}
+	if got := shuffle(c, 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if s := shuffle(&Config{Key: []byte("other")}, 100); reflect.DeepEqual(s, shuffle(c, 100)) {
+		t.Errorf("different keys give the same shuffle")
+	}
+	if err := Shuffle(c, -1, nil); err != ErrNegativeCount {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package blake2xs
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+
+	"github.com/dchest/blake2s"
+)
+
+// NonceSize is the size of nonce for NewStream in bytes.
+const NonceSize = 16
+
+type stream struct {
+	x   *XOF
+	buf [blake2s.Size]byte
+}
+
+// NewStream returns a cipher.Stream, which XORs data with the keystream
+// produced by the XOF of UnknownSize bytes with the given key and nonce.
+// Nonce must be exactly NonceSize bytes: the first 8 bytes are used as salt,
+// and the remaining 8 bytes as personalization. Since salt and
+// personalization are padded with zeros, shorter nonces would give the same
+// keystream as longer ones with trailing zeros, so they are rejected.
+//
+// The stream provides no authentication, so it should be combined with a MAC.
+// XORKeyStream panics if more than UnknownSize bytes of keystream is used.
+func NewStream(key, nonce []byte) (cipher.Stream, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrNonceSize
+	}
+	x, err := NewXOF(&Config{Key: key, Salt: nonce[:8], Person: nonce[8:]})
+	if err != nil {
+		return nil, err
+	}
+	return &stream{x: x}, nil
+}
+
+func (s *stream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("blake2xs: output smaller than input")
+	}
+	for len(src) > 0 {
+		n := len(src)
+		if n > len(s.buf) {
+			n = len(s.buf)
+		}
+		if _, err := io.ReadFull(s.x, s.buf[:n]); err != nil {
+			panic("blake2xs: keystream exhausted")
+		}
+		for i, v := range s.buf[:n] {
+			dst[i] = src[i] ^ v
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// Stream returns a reader of the XOF output of UnknownSize bytes with the
+// given key and no input. It is a deterministic source of bytes, useful for
+// generating reproducible test data; it is not a replacement for crypto/rand.
+// After UnknownSize bytes, the reader returns io.EOF.
+//
+// Stream panics if key is longer than 32 bytes.
+func Stream(key []byte) io.Reader {
+	x, err := NewXOF(&Config{Key: key})
+	if err != nil {
+		panic(err)
+	}
+	x.finalize()
+	return x
+}
+
+// NewCounterStream returns a new XOF of the given size with the given key,
+// and salt set to counter encoded as 8-byte big-endian integer. If size is
+// zero, output size is UnknownSize. Different counters give independent
+// outputs for the same key.
+func NewCounterStream(key []byte, counter uint64, size int) (*XOF, error) {
+	if !validSize(size) {
+		return nil, ErrSize
+	}
+	var salt [8]byte
+	binary.BigEndian.PutUint64(salt[:], counter)
+	return NewXOF(&Config{Size: uint16(size), Key: key, Salt: salt[:]})
+}
@@ -0,0 +1,107 @@
+package blake2xs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	key := []byte("key")
+	nonce := []byte("0123456789abcdef")
+	msg := make([]byte, 1000)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	// Keystream must be the XOF output.
+	h, _ := NewXOF(&Config{Key: key, Salt: nonce[:8], Person: nonce[8:]})
+	ks := make([]byte, len(msg))
+	h.Read(ks)
+
+	s, err := NewStream(key, nonce)
+	if err != nil {
+		t.Fatalf("error creating stream: %s", err)
+	}
+	ct := make([]byte, len(msg))
+	for i, n := 0, 1; i < len(msg); i, n = i+n, n+1 {
+		// Use varying chunk sizes.
+		if i+n > len(msg) {
+			n = len(msg) - i
+		}
+		s.XORKeyStream(ct[i:i+n], msg[i:i+n])
+	}
+	for i := range ct {
+		if ct[i] != msg[i]^ks[i] {
+			t.Fatalf("wrong ciphertext at %d", i)
+		}
+	}
+
+	// Decrypt in place.
+	s, _ = NewStream(key, nonce)
+	s.XORKeyStream(ct, ct)
+	if !bytes.Equal(ct, msg) {
+		t.Errorf("decryption failed")
+	}
+
+	for _, n := range []int{0, 8, 15, 17} {
+		if _, err := NewStream(key, make([]byte, n)); err != ErrNonceSize {
+			t.Errorf("expected ErrNonceSize for %d-byte nonce, got %v", n, err)
+		}
+	}
+}
+
+func TestStreamPanics(t *testing.T) {
+	s, _ := NewStream(nil, make([]byte, NonceSize))
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for short dst")
+		}
+	}()
+	s.XORKeyStream(make([]byte, 1), make([]byte, 2))
+}
+
+func TestStreamReader(t *testing.T) {
+	key := []byte("key")
+	want := make([]byte, UnknownSize)
+	Sum(want, nil, &Config{Key: key})
+	got, err := io.ReadAll(Stream(key))
+	if err != nil {
+		t.Fatalf("error reading: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrong stream output")
+	}
+	if w, ok := Stream(key).(io.Writer); ok {
+		if _, err := w.Write([]byte("x")); err != ErrWriteAfterRead {
+			t.Errorf("expected ErrWriteAfterRead, got %v", err)
+		}
+	}
+}
+
+func TestCounterStream(t *testing.T) {
+	key := []byte("key")
+	read := func(counter uint64) []byte {
+		h, err := NewCounterStream(key, counter, 64)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		out := make([]byte, 64)
+		h.Read(out)
+		return out
+	}
+	if !bytes.Equal(read(1), read(1)) {
+		t.Errorf("same counter gives different output")
+	}
+	if bytes.Equal(read(1), read(2)) {
+		t.Errorf("different counters give the same output")
+	}
+	want := make([]byte, 64)
+	Sum(want, nil, &Config{Key: key, Salt: []byte{0, 0, 0, 0, 0, 0, 1, 2}})
+	if got := read(0x0102); !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+	if _, err := NewCounterStream(key, 0, UnknownSize+1); err != ErrSize {
+		t.Errorf("expected ErrSize, got %v", err)
+	}
+}
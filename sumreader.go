@@ -0,0 +1,42 @@
+package blake2xs
+
+import "io"
+
+// SumReader is a reader that hashes data read from the underlying reader,
+// and then produces XOF output.
+//
+// Until Finalize is called, Read reads from the underlying reader and writes
+// the returned bytes into the XOF, returning errors (including io.EOF) from
+// the underlying reader unchanged, unless writing into the XOF fails. After
+// Finalize, Read returns XOF output. Only the bytes read before Finalize are
+// hashed.
+type SumReader struct {
+	src io.Reader
+	x   *XOF
+	fin bool
+}
+
+// NewSumReader returns a new SumReader, which reads from src and hashes it
+// with XOF configured with c.
+func NewSumReader(src io.Reader, c *Config) (*SumReader, error) {
+	x, err := NewXOF(c)
+	if err != nil {
+		return nil, err
+	}
+	return &SumReader{src: src, x: x}, nil
+}
+
+// Read reads from the underlying reader before Finalize, and XOF output after.
+func (r *SumReader) Read(p []byte) (n int, err error) {
+	if r.fin {
+		return r.x.Read(p)
+	}
+	n, err = r.src.Read(p)
+	if _, werr := r.x.Write(p[:n]); werr != nil {
+		return n, werr
+	}
+	return n, err
+}
+
+// Finalize switches the reader to producing XOF output.
+func (r *SumReader) Finalize() { r.fin = true }
@@ -0,0 +1,38 @@
+package blake2xs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSumReader(t *testing.T) {
+	input := make([]byte, 1000)
+	for i := range input {
+		input[i] = byte(i)
+	}
+	c := &Config{Size: 100, Key: []byte("key")}
+	r, err := NewSumReader(bytes.NewReader(input), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Errorf("proxied data differs from input")
+	}
+	r.Finalize()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 100)
+	if err := Sum(want, input, c); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Errorf("expected output %x, got %x", want, out)
+	}
+}
@@ -0,0 +1,117 @@
+package blake2xs
+
+import (
+	"hash"
+	"runtime"
+	"sync"
+
+	"github.com/dchest/blake2s"
+)
+
+// Tree hashing functions implement a two-level tree with unlimited fanout:
+// input is split into leaves of leafSize bytes (the last leaf may be
+// shorter), each leaf is hashed with BLAKE2s, and the root node, which is
+// the XOF, absorbs leaf digests in order.
+//
+// Leaf node offsets are leaf indexes, which occupy the lower 32 bits of
+// BLAKE2s node offset, while output size occupies the upper bits, same as
+// for the root node.
+
+// treeParams returns tree parameters for a node.
+func treeParams(leafSize uint32, depth uint8, offset uint64, last bool) *blake2s.Tree {
+	return &blake2s.Tree{
+		Fanout:        0,
+		MaxDepth:      2,
+		LeafSize:      leafSize,
+		NodeOffset:    offset,
+		NodeDepth:     depth,
+		InnerHashSize: blake2s.Size,
+		IsLastNode:    last,
+	}
+}
+
+// NewTreeLeaf returns a new hash for leaf i of a tree with the given leaf
+// size and output size. Last must be true for the last leaf. The hash should
+// absorb at most leafSize bytes of input.
+func NewTreeLeaf(leafSize uint32, size int, i uint32, last bool) (hash.Hash, error) {
+	if leafSize == 0 {
+		return nil, ErrTree
+	}
+	if !validSize(size) {
+		return nil, ErrSize
+	}
+	if size == 0 {
+		size = UnknownSize
+	}
+	return blake2s.New(&blake2s.Config{
+		Size: blake2s.Size,
+		Tree: treeParams(leafSize, 0, uint64(size)<<32|uint64(i), last),
+	})
+}
+
+// NewTreeRoot returns a new XOF for the root node of a tree with the given
+// leaf size and output size. It should absorb leaf digests in order.
+func NewTreeRoot(leafSize uint32, size int) (*XOF, error) {
+	if leafSize == 0 {
+		return nil, ErrTree
+	}
+	if !validSize(size) {
+		return nil, ErrSize
+	}
+	return NewXOF(&Config{
+		Size: uint16(size),
+		Tree: treeParams(leafSize, 1, 0, true),
+	})
+}
+
+// TreeSum computes the tree XOF of data with the given leaf size and
+// output size len(out), hashing leaves in parallel, and puts the result
+// into out.
+func TreeSum(out, data []byte, leafSize uint32) error {
+	if len(out) == 0 || !validSize(len(out)) {
+		return ErrSize
+	}
+	root, err := NewTreeRoot(leafSize, len(out))
+	if err != nil {
+		return err
+	}
+	n := (len(data) + int(leafSize) - 1) / int(leafSize)
+	if n == 0 {
+		n = 1 // empty input is a single empty leaf
+	}
+	digests := make([]byte, n*blake2s.Size)
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				leaf, err := NewTreeLeaf(leafSize, len(out), uint32(i), i == n-1)
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				start := i * int(leafSize)
+				end := start + int(leafSize)
+				if end > len(data) {
+					end = len(data)
+				}
+				leaf.Write(data[start:end])
+				leaf.Sum(digests[i*blake2s.Size : i*blake2s.Size])
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	root.Write(digests)
+	return root.Fill(out)
+}
@@ -0,0 +1,61 @@
+package blake2xs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTreeSum(t *testing.T) {
+	const leafSize = 1 << 20
+	data := make([]byte, 2*leafSize-100) // two leaves
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, size := range []int{1, 32, 100, UnknownSize} {
+		out := make([]byte, size)
+		if err := TreeSum(out, data, leafSize); err != nil {
+			t.Fatalf("size %d: error: %s", size, err)
+		}
+
+		// Compute sequentially.
+		root, _ := NewTreeRoot(leafSize, size)
+		for i, b := range [][]byte{data[:leafSize], data[leafSize:]} {
+			leaf, err := NewTreeLeaf(leafSize, size, uint32(i), i == 1)
+			if err != nil {
+				t.Fatalf("size %d: error creating leaf: %s", size, err)
+			}
+			leaf.Write(b)
+			root.Write(leaf.Sum(nil))
+		}
+		want := make([]byte, size)
+		root.Read(want)
+		if !bytes.Equal(out, want) {
+			t.Errorf("size %d: parallel output differs from sequential", size)
+		}
+
+		// Tree output is different from sequential XOF.
+		seq := make([]byte, size)
+		Sum(seq, data, nil)
+		if size > 1 && bytes.Equal(out, seq) {
+			t.Errorf("size %d: tree output equals sequential XOF output", size)
+		}
+	}
+}
+
+func TestTreeSumEmpty(t *testing.T) {
+	out := make([]byte, 32)
+	if err := TreeSum(out, nil, 64); err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	root, _ := NewTreeRoot(64, 32)
+	leaf, _ := NewTreeLeaf(64, 32, 0, true)
+	root.Write(leaf.Sum(nil))
+	want := make([]byte, 32)
+	root.Read(want)
+	if !bytes.Equal(out, want) {
+		t.Errorf("wrong output for empty input")
+	}
+	if err := TreeSum(out, nil, 0); err != ErrTree {
+		t.Errorf("expected ErrTree for zero leaf size, got %v", err)
+	}
+}
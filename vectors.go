@@ -0,0 +1,118 @@
+package blake2xs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+)
+
+// Vector is a BLAKE2Xs test vector. Fields are hex-encoded.
+type Vector struct {
+	Key    string
+	Input  string
+	Output string // output of len(Output)/2 bytes, which is also XOF size
+}
+
+const (
+	vectorInput = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+	vectorKey   = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+)
+
+// Vectors is a subset of the official BLAKE2Xs test vectors.
+var Vectors = []Vector{
+	{
+		"",
+		vectorInput,
+		"99",
+	},
+	{
+		"",
+		vectorInput,
+		"91cab802b466092897c7639a02acf529ca61864e5e8c8e422b3a9381a95154d1",
+	},
+	{
+		"",
+		vectorInput,
+		"0253f5487d927a5d35d0089ad9cab2d7515b65d332e870c78d1229d1c584bec3d5",
+	},
+	{
+		"",
+		vectorInput,
+		"57aa5c761e7cfa573c48785109ad76445441de0ee0f9fe9dd4abb920b7cb5f608fc9a029f85ec478a130f194372b6112f5f2d10408e0d23f696cc9e313b7f1d3",
+	},
+	{
+		"",
+		vectorInput,
+		"5e3f0046de3d99d5de3d01ef2947b812714e09af342d9ea03311565a748ac0842540e0504aa8a54d4c7563bd8948d36177d88cc7b14777b2c7930252d4ec1c1a0fa0e21ff2889f41615c9b828b179c4778f314751cc58fbe386bb6cc48b1a729cafd9f2f",
+	},
+	{
+		vectorKey,
+		vectorInput,
+		"0e",
+	},
+	{
+		vectorKey,
+		vectorInput,
+		"a4fe2bd0f96a215fa7164ae1a405f4030a586c12b0c29806a099d7d7fdd8dd72",
+	},
+	{
+		vectorKey,
+		vectorInput,
+		"7dce710a20f42ab687ec6ea83b53faaa418229ce0d5a2ff2a5e66defb0b65c03c9",
+	},
+	{
+		vectorKey,
+		vectorInput,
+		"ec470d0aa932c78c5bcf86203ec0014314114765fa679c3daef214f883a17e1b4ca12f44433772a6e4ef685c904b2fc35586c6bd88f325b965968b06d808d73f",
+	},
+	{
+		vectorKey,
+		vectorInput,
+		"3366860c77804fe0b4f368b02bb5b0d150821d957e3ba37842da9fc8d336e9d702c8446ecafbd19d79b868702f32405853bc17695873a7306e0ce4573cd9ac0b7fc7dd35534d7635198d152a1802f7d8d6a4bb07600fcdaacfaa1c3f40a09bc02e974c99",
+	},
+}
+
+// CheckVector creates the XOF with config c, writes input, reads
+// len(expected) bytes of output, and compares them with expected.
+// It returns ErrVectorMismatch if output differs.
+func CheckVector(c *Config, input, expected []byte) error {
+	x, err := NewXOF(c)
+	if err != nil {
+		return err
+	}
+	if _, err := x.Write(input); err != nil {
+		return err
+	}
+	out := make([]byte, len(expected))
+	if _, err := io.ReadFull(x, out); err != nil {
+		return err
+	}
+	if !bytes.Equal(out, expected) {
+		return ErrVectorMismatch
+	}
+	return nil
+}
+
+// Check checks the vector with CheckVector.
+func (v Vector) Check() error {
+	key, err := hex.DecodeString(v.Key)
+	if err != nil {
+		return err
+	}
+	input, err := hex.DecodeString(v.Input)
+	if err != nil {
+		return err
+	}
+	expected, err := hex.DecodeString(v.Output)
+	if err != nil {
+		return err
+	}
+	if len(expected) == 0 || len(expected) > UnknownSize {
+		return ErrSize
+	}
+	c := &Config{Size: uint16(len(expected))}
+	if len(key) > 0 {
+		c.Key = key
+	}
+	return CheckVector(c, input, expected)
+}
@@ -0,0 +1,16 @@
+package blake2xs
+
+import "testing"
+
+func TestVectors(t *testing.T) {
+	for i, v := range Vectors {
+		if err := v.Check(); err != nil {
+			t.Errorf("%d: %s", i, err)
+		}
+	}
+	v := Vectors[0]
+	v.Output = "00"
+	if err := v.Check(); err != ErrVectorMismatch {
+		t.Errorf("expected ErrVectorMismatch, got %v", err)
+	}
+}